@@ -0,0 +1,139 @@
+package background
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"strings"
+
+	"gocv.io/x/gocv"
+
+	"tracker/types"
+)
+
+// Names of the supported background subtraction algorithms
+const (
+	MOG2 = "MOG2"
+	KNN  = "KNN"
+)
+
+// New creates the background subtractor selected in the config
+func New(config types.TrackingConfig) (types.BackgroundSubtractor, error) {
+	switch strings.ToUpper(config.BackgroundSubtractor) {
+	case MOG2:
+		return &mog2{
+			sub:          gocv.NewBackgroundSubtractorMOG2WithParams(config.BackgroundHistory, config.BackgroundVarThreshold, config.BackgroundDetectShadows),
+			learningRate: config.BackgroundLearningRate,
+		}, nil
+	case KNN:
+		if config.BackgroundLearningRate >= 0 {
+			log.Printf("KNN background subtraction doesn't support a fixed learning rate, using automatic rate")
+		}
+		return &knn{
+			sub: gocv.NewBackgroundSubtractorKNNWithParams(config.BackgroundHistory, config.BackgroundDist2Threshold, config.BackgroundDetectShadows),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown background subtractor %q, available: %s, %s", config.BackgroundSubtractor, MOG2, KNN)
+	}
+}
+
+// mog2 is a Gaussian mixture background model with an optional fixed learning rate
+type mog2 struct {
+	sub          gocv.BackgroundSubtractorMOG2
+	learningRate float64
+}
+
+// Apply updates the model with src and writes the foreground mask to dst
+func (m *mog2) Apply(src gocv.Mat, dst *gocv.Mat) error {
+	if m.learningRate < 0 {
+		return m.sub.Apply(src, dst)
+	}
+	return m.sub.ApplyWithLearningRate(src, dst, m.learningRate)
+}
+
+// Close releases the model
+func (m *mog2) Close() error {
+	return m.sub.Close()
+}
+
+// knn is a K-nearest neighbours background model
+type knn struct {
+	sub gocv.BackgroundSubtractorKNN
+}
+
+// Apply updates the model with src and writes the foreground mask to dst
+func (k *knn) Apply(src gocv.Mat, dst *gocv.Mat) error {
+	return k.sub.Apply(src, dst)
+}
+
+// Close releases the model
+func (k *knn) Close() error {
+	return k.sub.Close()
+}
+
+// CleanMask post-processes a foreground mask in place with the cleanup steps
+// enabled in the config: removing shadows, eroding, dilating, opening,
+// closing and median blurring, in that order. The mask is at the detection
+// scale, so the kernel sizes, given in working frame pixels, are scaled down
+// with it.
+func CleanMask(mask *gocv.Mat, config types.TrackingConfig) error {
+	if config.MaskShadowThreshold > 0 {
+		// Shadows are marked gray (127), keep only definite foreground
+		gocv.Threshold(*mask, mask, float32(config.MaskShadowThreshold), 255, gocv.ThresholdBinary)
+	}
+
+	if config.MaskErodeIterations > 0 || config.MaskDilateIterations > 0 {
+		kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Pt(3, 3))
+		defer func() { _ = kernel.Close() }()
+
+		for i := 0; i < config.MaskErodeIterations; i++ {
+			if err := gocv.Erode(*mask, mask, kernel); err != nil {
+				return fmt.Errorf("error eroding mask: %v", err)
+			}
+		}
+		for i := 0; i < config.MaskDilateIterations; i++ {
+			if err := gocv.Dilate(*mask, mask, kernel); err != nil {
+				return fmt.Errorf("error dilating mask: %v", err)
+			}
+		}
+	}
+
+	if err := morph(mask, gocv.MorphOpen, scaleSize(config.MaskOpenSize, config.DetectionScale)); err != nil {
+		return fmt.Errorf("error opening mask: %v", err)
+	}
+	if err := morph(mask, gocv.MorphClose, scaleSize(config.MaskCloseSize, config.DetectionScale)); err != nil {
+		return fmt.Errorf("error closing mask: %v", err)
+	}
+
+	if median := scaleSize(config.MaskMedianSize, config.DetectionScale); median > 1 {
+		// The aperture must be odd
+		size := median | 1
+		if err := gocv.MedianBlur(*mask, mask, size); err != nil {
+			return fmt.Errorf("error blurring mask: %v", err)
+		}
+	}
+	return nil
+}
+
+// morph applies a morphological operation with an elliptic kernel of the
+// given size, or does nothing when size is 0
+func morph(mask *gocv.Mat, op gocv.MorphType, size int) error {
+	if size <= 0 {
+		return nil
+	}
+
+	kernel := gocv.GetStructuringElement(gocv.MorphEllipse, image.Pt(size, size))
+	defer func() { _ = kernel.Close() }()
+
+	return gocv.MorphologyEx(*mask, mask, op, kernel)
+}
+
+// scaleSize scales a kernel size to the detection scale, keeping enabled
+// steps at least 1 pixel
+func scaleSize(size int, scale float64) int {
+	if size <= 0 || scale <= 0 || scale >= 1 {
+		return size
+	}
+	return max(int(math.Round(float64(size)*scale)), 1)
+}
@@ -0,0 +1,89 @@
+package detector
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"strings"
+
+	"gocv.io/x/gocv"
+
+	"tracker/types"
+)
+
+// CascadeDetector finds objects such as faces or bodies with a Haar or LBP cascade classifier
+type CascadeDetector struct {
+	classifier   gocv.CascadeClassifier
+	label        string
+	scaleFactor  float64
+	minNeighbors int
+	minSize      image.Point
+	gray         gocv.Mat
+}
+
+// NewCascade loads the cascade XML file configured for the detector
+func NewCascade(config types.TrackingConfig) (*CascadeDetector, error) {
+	if config.CascadeFile == "" {
+		return nil, fmt.Errorf("the cascade detector needs a cascade XML file")
+	}
+
+	classifier := gocv.NewCascadeClassifier()
+	if !classifier.Load(config.CascadeFile) {
+		_ = classifier.Close()
+		return nil, fmt.Errorf("could not load cascade %s", config.CascadeFile)
+	}
+
+	// The cascade sees frames at the detection scale
+	minSide := config.MinROISize
+	if config.DetectionScale > 0 && config.DetectionScale < 1 {
+		minSide = int(float64(minSide) * config.DetectionScale)
+	}
+
+	return &CascadeDetector{
+		classifier:   classifier,
+		label:        cascadeLabel(config.CascadeFile),
+		scaleFactor:  config.CascadeScaleFactor,
+		minNeighbors: config.CascadeMinNeighbors,
+		// Objects smaller than the smallest tracking ROI can't be tracked anyway
+		minSize: image.Pt(minSide, minSide),
+		gray:    gocv.NewMat(),
+	}, nil
+}
+
+// Detect runs the cascade on the frame. Cascades don't score their detections,
+// so every object has a score of 1.
+func (d *CascadeDetector) Detect(frame gocv.Mat) ([]types.DetectedObject, error) {
+	if err := gocv.CvtColor(frame, &d.gray, gocv.ColorBGRToGray); err != nil {
+		return nil, fmt.Errorf("error converting to grayscale: %v", err)
+	}
+	if err := gocv.EqualizeHist(d.gray, &d.gray); err != nil {
+		return nil, fmt.Errorf("error equalizing histogram: %v", err)
+	}
+
+	rects := d.classifier.DetectMultiScaleWithParams(d.gray, d.scaleFactor, d.minNeighbors, 0, d.minSize, image.Point{})
+
+	objects := make([]types.DetectedObject, len(rects))
+	for i, rect := range rects {
+		objects[i] = types.DetectedObject{Rect: rect, Label: d.label, Score: 1}
+	}
+	return objects, nil
+}
+
+// Close releases the classifier
+func (d *CascadeDetector) Close() error {
+	_ = d.gray.Close()
+	return d.classifier.Close()
+}
+
+// cascadeLabel names detections after the cascade file, so that
+// haarcascade_frontalface_default.xml labels them "frontalface"
+func cascadeLabel(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for _, prefix := range []string{"haarcascade_", "lbpcascade_"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	for _, suffix := range []string{"_default", "_alt2", "_alt_tree", "_alt"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	return name
+}
@@ -0,0 +1,58 @@
+package detector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"tracker/downscale"
+	"tracker/types"
+)
+
+// Names of the supported object detectors
+const (
+	DNN     = "dnn"
+	Cascade = "cascade"
+)
+
+// New creates the object detector selected in the config, running at the
+// detection scale, or returns nil when auto-tracking relies on motion detection only
+func New(config types.TrackingConfig) (types.ObjectDetector, error) {
+	switch strings.ToLower(config.Detector) {
+	case "":
+		return nil, nil
+	case DNN:
+		d, err := NewDNN(config)
+		if err != nil {
+			return nil, err
+		}
+		return downscale.Detector(d, config.DetectionScale), nil
+	case Cascade:
+		d, err := NewCascade(config)
+		if err != nil {
+			return nil, err
+		}
+		return downscale.Detector(d, config.DetectionScale), nil
+	default:
+		return nil, fmt.Errorf("unknown detector %q, available: %s, %s", config.Detector, DNN, Cascade)
+	}
+}
+
+// readLines reads the non-empty lines of a text file
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,183 @@
+package detector
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"gocv.io/x/gocv"
+
+	"tracker/types"
+)
+
+// cocoClasses are the class names of models trained on COCO, used when no classes file is configured
+var cocoClasses = []string{
+	"person", "bicycle", "car", "motorcycle", "airplane", "bus", "train", "truck", "boat", "traffic light",
+	"fire hydrant", "stop sign", "parking meter", "bench", "bird", "cat", "dog", "horse", "sheep", "cow",
+	"elephant", "bear", "zebra", "giraffe", "backpack", "umbrella", "handbag", "tie", "suitcase", "frisbee",
+	"skis", "snowboard", "sports ball", "kite", "baseball bat", "baseball glove", "skateboard", "surfboard", "tennis racket", "bottle",
+	"wine glass", "cup", "fork", "knife", "spoon", "bowl", "banana", "apple", "sandwich", "orange",
+	"broccoli", "carrot", "hot dog", "pizza", "donut", "cake", "chair", "couch", "potted plant", "bed",
+	"dining table", "toilet", "tv", "laptop", "mouse", "remote", "keyboard", "cell phone", "microwave", "oven",
+	"toaster", "sink", "refrigerator", "book", "clock", "vase", "scissors", "teddy bear", "hair drier", "toothbrush",
+}
+
+// DNNDetector runs a YOLO-style ONNX object detection model on the CPU
+type DNNDetector struct {
+	net        gocv.Net
+	classes    []string
+	allowed    map[int]bool
+	inputSize  image.Point
+	confidence float32
+	nms        float32
+}
+
+// NewDNN loads the ONNX model configured for the detector
+func NewDNN(config types.TrackingConfig) (*DNNDetector, error) {
+	if config.DetectorModel == "" {
+		return nil, fmt.Errorf("the dnn detector needs a model file")
+	}
+
+	classes := cocoClasses
+	if config.DetectorClassesFile != "" {
+		var err error
+		classes, err = readLines(config.DetectorClassesFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read detector classes: %v", err)
+		}
+	}
+
+	// Resolve the allow-list to class indices, an empty list allows every class
+	var allowed map[int]bool
+	for _, name := range config.DetectorClasses {
+		found := false
+		for i, class := range classes {
+			if strings.EqualFold(class, name) {
+				if allowed == nil {
+					allowed = make(map[int]bool)
+				}
+				allowed[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown detector class %q", name)
+		}
+	}
+
+	net := gocv.ReadNet(config.DetectorModel, "")
+	if net.Empty() {
+		_ = net.Close()
+		return nil, fmt.Errorf("could not load detector model %s", config.DetectorModel)
+	}
+	if err := net.SetPreferableBackend(gocv.NetBackendDefault); err != nil {
+		_ = net.Close()
+		return nil, fmt.Errorf("could not set detector backend: %v", err)
+	}
+	if err := net.SetPreferableTarget(gocv.NetTargetCPU); err != nil {
+		_ = net.Close()
+		return nil, fmt.Errorf("could not set detector target: %v", err)
+	}
+
+	return &DNNDetector{
+		net:        net,
+		classes:    classes,
+		allowed:    allowed,
+		inputSize:  image.Pt(config.DetectorInputSize, config.DetectorInputSize),
+		confidence: float32(config.DetectorConfidence),
+		nms:        float32(config.DetectorNMS),
+	}, nil
+}
+
+// Detect runs the model on the frame and returns the allowed objects that
+// pass the confidence threshold after non-maximum suppression
+func (d *DNNDetector) Detect(frame gocv.Mat) ([]types.DetectedObject, error) {
+	blob := gocv.BlobFromImage(frame, 1.0/255.0, d.inputSize, gocv.NewScalar(0, 0, 0, 0), true, false)
+	defer func() { _ = blob.Close() }()
+
+	d.net.SetInput(blob, "")
+	output := d.net.Forward("")
+	defer func() { _ = output.Close() }()
+
+	dims := output.Size()
+	if len(dims) != 3 {
+		return nil, fmt.Errorf("unexpected detector output shape %v", dims)
+	}
+	data, err := output.DataPtrFloat32()
+	if err != nil {
+		return nil, fmt.Errorf("could not read detector output: %v", err)
+	}
+
+	// YOLOv5-style models output [1, boxes, 5+classes] including an objectness
+	// score, YOLOv8-style models output [1, 4+classes, boxes] without one
+	transposed := dims[1] < dims[2]
+	boxes, attributes := dims[1], dims[2]
+	classOffset := 5
+	if transposed {
+		boxes, attributes = dims[2], dims[1]
+		classOffset = 4
+	}
+	at := func(box, attribute int) float32 {
+		if transposed {
+			return data[attribute*boxes+box]
+		}
+		return data[box*attributes+attribute]
+	}
+
+	scaleX := float32(frame.Cols()) / float32(d.inputSize.X)
+	scaleY := float32(frame.Rows()) / float32(d.inputSize.Y)
+
+	var rects []image.Rectangle
+	var scores []float32
+	var classIDs []int
+	for box := 0; box < boxes; box++ {
+		classID, score := -1, float32(0)
+		for class := 0; class < attributes-classOffset; class++ {
+			if s := at(box, classOffset+class); s > score {
+				classID, score = class, s
+			}
+		}
+		if classOffset == 5 {
+			score *= at(box, 4)
+		}
+		if classID < 0 || score < d.confidence || (d.allowed != nil && !d.allowed[classID]) {
+			continue
+		}
+
+		cx, cy, w, h := at(box, 0)*scaleX, at(box, 1)*scaleY, at(box, 2)*scaleX, at(box, 3)*scaleY
+		rects = append(rects, image.Rect(int(cx-w/2), int(cy-h/2), int(cx+w/2), int(cy+h/2)))
+		scores = append(scores, score)
+		classIDs = append(classIDs, classID)
+	}
+	if len(rects) == 0 {
+		return nil, nil
+	}
+
+	bounds := image.Rect(0, 0, frame.Cols(), frame.Rows())
+	var objects []types.DetectedObject
+	for _, i := range gocv.NMSBoxes(rects, scores, d.confidence, d.nms) {
+		rect := rects[i].Intersect(bounds)
+		if rect.Empty() {
+			continue
+		}
+		objects = append(objects, types.DetectedObject{
+			Rect:  rect,
+			Label: d.label(classIDs[i]),
+			Score: float64(scores[i]),
+		})
+	}
+	return objects, nil
+}
+
+// label returns the name of a class index
+func (d *DNNDetector) label(classID int) string {
+	if classID < len(d.classes) {
+		return d.classes[classID]
+	}
+	return fmt.Sprintf("class %d", classID)
+}
+
+// Close releases the network
+func (d *DNNDetector) Close() error {
+	return d.net.Close()
+}
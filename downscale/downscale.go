@@ -0,0 +1,157 @@
+package downscale
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+
+	"tracker/motion"
+	"tracker/types"
+)
+
+// ParseScale parses a stage scale given as a decimal like 0.25 or a fraction like 1/4
+func ParseScale(s string) (float64, error) {
+	num, den, isFraction := strings.Cut(s, "/")
+	scale, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err == nil && isFraction {
+		var d float64
+		d, err = strconv.ParseFloat(strings.TrimSpace(den), 64)
+		scale /= d
+	}
+	if err != nil || math.IsNaN(scale) || scale <= 0 || scale > 1 {
+		return 0, fmt.Errorf("invalid scale %q, expected a number in (0, 1] like 0.5 or 1/2", s)
+	}
+	return scale, nil
+}
+
+// Resize writes src scaled by scale to dst
+func Resize(src gocv.Mat, dst *gocv.Mat, scale float64) error {
+	return gocv.Resize(src, dst, image.Point{}, scale, scale, gocv.InterpolationArea)
+}
+
+// Rect scales a rectangle about the origin, rounding to whole pixels
+func Rect(r image.Rectangle, scale float64) image.Rectangle {
+	if r.Empty() {
+		return r
+	}
+	return image.Rect(
+		int(math.Round(float64(r.Min.X)*scale)), int(math.Round(float64(r.Min.Y)*scale)),
+		int(math.Round(float64(r.Max.X)*scale)), int(math.Round(float64(r.Max.Y)*scale)),
+	)
+}
+
+// Affine returns the transform m in coordinates scaled by scale. Only the
+// translation depends on the resolution.
+func Affine(m motion.Affine, scale float64) motion.Affine {
+	m[2] *= scale
+	m[5] *= scale
+	return m
+}
+
+// Tracker runs t on frames downscaled by scale and maps its boxes back to
+// full resolution. A scale of 1 or more returns t itself.
+func Tracker(t gocv.Tracker, scale float64) gocv.Tracker {
+	if scale >= 1 {
+		return t
+	}
+	return &tracker{Tracker: t, scale: scale, small: gocv.NewMat()}
+}
+
+type tracker struct {
+	gocv.Tracker
+	scale float64
+	small gocv.Mat
+}
+
+// Init initializes the tracker with a full resolution box
+func (t *tracker) Init(frame gocv.Mat, rect image.Rectangle) bool {
+	if err := Resize(frame, &t.small, t.scale); err != nil {
+		return false
+	}
+	return t.Tracker.Init(t.small, Rect(rect, t.scale))
+}
+
+// Update returns the full resolution box of the target in the frame
+func (t *tracker) Update(frame gocv.Mat) (image.Rectangle, bool) {
+	if err := Resize(frame, &t.small, t.scale); err != nil {
+		return image.Rectangle{}, false
+	}
+	rect, ok := t.Tracker.Update(t.small)
+	return Rect(rect, 1/t.scale), ok
+}
+
+// Close releases the tracker
+func (t *tracker) Close() error {
+	_ = t.small.Close()
+	return t.Tracker.Close()
+}
+
+// Detector runs d on frames downscaled by scale and maps the detected objects
+// back to full resolution. A scale of 1 or more returns d itself.
+func Detector(d types.ObjectDetector, scale float64) types.ObjectDetector {
+	if scale >= 1 {
+		return d
+	}
+	return &detector{ObjectDetector: d, scale: scale, small: gocv.NewMat()}
+}
+
+type detector struct {
+	types.ObjectDetector
+	scale float64
+	small gocv.Mat
+}
+
+// Detect returns the objects in the frame in full resolution coordinates
+func (d *detector) Detect(frame gocv.Mat) ([]types.DetectedObject, error) {
+	if err := Resize(frame, &d.small, d.scale); err != nil {
+		return nil, fmt.Errorf("error downscaling frame: %v", err)
+	}
+	objects, err := d.ObjectDetector.Detect(d.small)
+	for i := range objects {
+		objects[i].Rect = Rect(objects[i].Rect, 1/d.scale)
+	}
+	return objects, err
+}
+
+// Close releases the detector
+func (d *detector) Close() error {
+	_ = d.small.Close()
+	return d.ObjectDetector.Close()
+}
+
+// CameraMotion estimates camera motion on frames downscaled by scale and
+// returns it in full resolution coordinates. A scale of 1 or more returns e itself.
+func CameraMotion(e types.CameraMotionEstimator, scale float64) types.CameraMotionEstimator {
+	if scale >= 1 {
+		return e
+	}
+	return &cameraMotion{CameraMotionEstimator: e, scale: scale, small: gocv.NewMat()}
+}
+
+type cameraMotion struct {
+	types.CameraMotionEstimator
+	scale float64
+	small gocv.Mat
+}
+
+// Estimate returns the motion from the previous frame in full resolution coordinates
+func (c *cameraMotion) Estimate(frame gocv.Mat) (motion.Affine, bool) {
+	if err := Resize(frame, &c.small, c.scale); err != nil {
+		return motion.Identity(), false
+	}
+	m, ok := c.CameraMotionEstimator.Estimate(c.small)
+	if !ok {
+		return m, false
+	}
+	return Affine(m, 1/c.scale), true
+}
+
+// Close releases the estimator
+func (c *cameraMotion) Close() error {
+	_ = c.small.Close()
+	return c.CameraMotionEstimator.Close()
+}
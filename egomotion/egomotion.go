@@ -0,0 +1,130 @@
+package egomotion
+
+import (
+	"image"
+	"image/color"
+	"log"
+
+	"gocv.io/x/gocv"
+
+	"tracker/downscale"
+	"tracker/motion"
+	"tracker/types"
+)
+
+// Parameters of feature matching and robust motion fitting
+const (
+	// ratioTest keeps a match only if it is clearly better than the second best
+	ratioTest = 0.75
+	// ransacThreshold is the largest reprojection error of an inlier in px
+	ransacThreshold  = 3.0
+	ransacIterations = 2000
+	ransacConfidence = 0.99
+	refineIterations = 10
+)
+
+// Estimator estimates the global motion of the camera between consecutive
+// frames by matching ORB features and fitting a similarity transform to them.
+// Independently moving objects are rejected as RANSAC outliers as long as the
+// background makes up most of the frame.
+type Estimator struct {
+	orb        gocv.ORB
+	matcher    gocv.BFMatcher
+	gray       gocv.Mat
+	noMask     gocv.Mat
+	minInliers int
+
+	prevKeypoints   []gocv.KeyPoint
+	prevDescriptors gocv.Mat
+}
+
+// New creates a camera motion estimator with the feature count and inlier
+// minimum from the config, running at the detection scale
+func New(config types.TrackingConfig) types.CameraMotionEstimator {
+	return downscale.CameraMotion(&Estimator{
+		orb:             gocv.NewORBWithParams(config.CameraMotionFeatures, 1.2, 8, 31, 0, 2, gocv.ORBScoreTypeHarris, 31, 20),
+		matcher:         gocv.NewBFMatcherWithParams(gocv.NormHamming, false),
+		gray:            gocv.NewMat(),
+		noMask:          gocv.NewMat(),
+		minInliers:      config.CameraMotionMinInliers,
+		prevDescriptors: gocv.NewMat(),
+	}, config.DetectionScale)
+}
+
+// Estimate returns the transform mapping points of the previous frame to the
+// frame. It reports false when the frames can't be matched reliably, e.g. on
+// the first frame or in a featureless scene.
+func (e *Estimator) Estimate(frame gocv.Mat) (motion.Affine, bool) {
+	if err := gocv.CvtColor(frame, &e.gray, gocv.ColorBGRToGray); err != nil {
+		log.Printf("Error converting to grayscale: %v", err)
+		return motion.Identity(), false
+	}
+
+	keypoints, descriptors := e.orb.DetectAndCompute(e.gray, e.noMask)
+	m, ok := e.match(keypoints, descriptors)
+
+	// The frame is what the next one is matched against
+	_ = e.prevDescriptors.Close()
+	e.prevKeypoints, e.prevDescriptors = keypoints, descriptors
+	return m, ok
+}
+
+// match fits the motion from the previous features to the given ones
+func (e *Estimator) match(keypoints []gocv.KeyPoint, descriptors gocv.Mat) (motion.Affine, bool) {
+	if descriptors.Empty() || e.prevDescriptors.Empty() {
+		return motion.Identity(), false
+	}
+
+	var from, to []gocv.Point2f
+	for _, pair := range e.matcher.KnnMatch(descriptors, e.prevDescriptors, 2) {
+		if len(pair) < 2 || pair[0].Distance >= ratioTest*pair[1].Distance {
+			continue
+		}
+		prev, cur := e.prevKeypoints[pair[0].TrainIdx], keypoints[pair[0].QueryIdx]
+		from = append(from, gocv.Point2f{X: float32(prev.X), Y: float32(prev.Y)})
+		to = append(to, gocv.Point2f{X: float32(cur.X), Y: float32(cur.Y)})
+	}
+	if len(from) < e.minInliers {
+		return motion.Identity(), false
+	}
+
+	fromPts := gocv.NewPoint2fVectorFromPoints(from)
+	defer fromPts.Close()
+	toPts := gocv.NewPoint2fVectorFromPoints(to)
+	defer toPts.Close()
+	inliers := gocv.NewMat()
+	defer inliers.Close()
+
+	fit := gocv.EstimateAffinePartial2DWithParams(fromPts, toPts, inliers, int(gocv.HomographyMethodRANSAC),
+		ransacThreshold, ransacIterations, ransacConfidence, refineIterations)
+	defer fit.Close()
+	if fit.Empty() || gocv.CountNonZero(inliers) < e.minInliers {
+		return motion.Identity(), false
+	}
+
+	return motion.Affine{
+		fit.GetDoubleAt(0, 0), fit.GetDoubleAt(0, 1), fit.GetDoubleAt(0, 2),
+		fit.GetDoubleAt(1, 0), fit.GetDoubleAt(1, 1), fit.GetDoubleAt(1, 2),
+	}, true
+}
+
+// Warp writes src transformed by m to dst, which gets the given size. Pixels
+// mapped from outside src are filled according to border: black for
+// BorderConstant, the nearest edge pixel for BorderReplicate.
+func Warp(src gocv.Mat, dst *gocv.Mat, m motion.Affine, size image.Point, border gocv.BorderType) error {
+	mat := gocv.NewMatWithSize(2, 3, gocv.MatTypeCV64F)
+	defer mat.Close()
+	for i, v := range m {
+		mat.SetDoubleAt(i/3, i%3, v)
+	}
+	return gocv.WarpAffineWithParams(src, dst, mat, size, gocv.InterpolationNearestNeighbor, border, color.RGBA{})
+}
+
+// Close releases the feature detector and matcher
+func (e *Estimator) Close() error {
+	_ = e.gray.Close()
+	_ = e.noMask.Close()
+	_ = e.prevDescriptors.Close()
+	_ = e.matcher.Close()
+	return e.orb.Close()
+}
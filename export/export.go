@@ -0,0 +1,379 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"tracker/fsm"
+	"tracker/motion"
+	"tracker/preprocess"
+	"tracker/types"
+	"tracker/zones"
+)
+
+// Rect is a bounding box in frame pixel coordinates
+type Rect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// FrameRecord is the tracking result for a single frame
+type FrameRecord struct {
+	Frame       int     `json:"frame"`
+	SourceIndex int     `json:"source_index"`
+	SourceName  string  `json:"source_name,omitempty"`
+	TimestampMs float64 `json:"timestamp_ms"`
+	State       string  `json:"state"`
+	Tracking    bool    `json:"tracking"`
+	Success     bool    `json:"success"`
+	Confidence  float64 `json:"confidence"`
+	Label       string  `json:"label,omitempty"`
+	Rect        *Rect   `json:"rect,omitempty"`
+	Predicted   *Rect   `json:"predicted,omitempty"`
+
+	Filter      *FilterRecord      `json:"filter,omitempty"`
+	Speed       *SpeedRecord       `json:"speed,omitempty"`
+	Trajectory  []PointRecord      `json:"trajectory,omitempty"`
+	Tracks      []TrackRecord      `json:"tracks,omitempty"`
+	Transitions []TransitionRecord `json:"transitions,omitempty"`
+	Crossings   []CrossingRecord   `json:"crossings,omitempty"`
+	Tripwires   []TripwireRecord   `json:"tripwires,omitempty"`
+	ZoneEvents  []ZoneEventRecord  `json:"zone_events,omitempty"`
+	Zones       []ZoneRecord       `json:"zones,omitempty"`
+}
+
+// SummaryRecord is the last line of an export, holding the totals of the
+// session. It is told apart from frame records by its summary key.
+type SummaryRecord struct {
+	Summary SessionRecord `json:"summary"`
+}
+
+// SessionRecord holds the totals of a session
+type SessionRecord struct {
+	Frames     int               `json:"frames"`
+	Tripwires  []TripwireRecord  `json:"tripwires,omitempty"`
+	ZoneEvents []ZoneEventRecord `json:"zone_events,omitempty"`
+	Zones      []ZoneRecord      `json:"zones,omitempty"`
+}
+
+// ZoneEventRecord is an object entering, leaving or loitering in a watch zone in the frame
+type ZoneEventRecord struct {
+	Zone    string  `json:"zone"`
+	Object  string  `json:"object"`
+	Event   string  `json:"event"`
+	DwellMs float64 `json:"dwell_ms,omitempty"`
+}
+
+// ZoneRecord holds the occupancy and running dwell statistics of a watch zone
+type ZoneRecord struct {
+	Name           string  `json:"name"`
+	Occupancy      int     `json:"occupancy"`
+	Loitering      int     `json:"loitering"`
+	Visits         int     `json:"visits"`
+	TotalDwellMs   float64 `json:"total_dwell_ms"`
+	AverageDwellMs float64 `json:"average_dwell_ms"`
+	LongestDwellMs float64 `json:"longest_dwell_ms"`
+}
+
+// CrossingRecord is a tripwire line crossed in the frame
+type CrossingRecord struct {
+	Line      string `json:"line"`
+	Object    string `json:"object"`
+	Direction string `json:"direction"`
+}
+
+// TripwireRecord holds the running counts of a tripwire line
+type TripwireRecord struct {
+	Name string `json:"name"`
+	In   int    `json:"in"`
+	Out  int    `json:"out"`
+}
+
+// PointRecord is a timestamped trajectory point
+type PointRecord struct {
+	X           int     `json:"x"`
+	Y           int     `json:"y"`
+	TimestampMs float64 `json:"timestamp_ms"`
+}
+
+// TransitionRecord is a tracking state change that happened since the previous frame record
+type TransitionRecord struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+// FilterRecord is the motion model state of a target: center position (px),
+// velocity (px/s) and the 4×4 covariance of [x, y, vx, vy]
+type FilterRecord struct {
+	X          float64       `json:"x"`
+	Y          float64       `json:"y"`
+	VX         float64       `json:"vx"`
+	VY         float64       `json:"vy"`
+	Covariance [4][4]float64 `json:"covariance"`
+}
+
+// SpeedRecord is the smoothed velocity (px/s) of a target with its speed in
+// px/s and m/s (if the scale is known) and its heading in degrees clockwise from up
+type SpeedRecord struct {
+	VX         float64 `json:"vx"`
+	VY         float64 `json:"vy"`
+	PxPerS     float64 `json:"px_per_s"`
+	MPerS      float64 `json:"m_per_s,omitempty"`
+	HeadingDeg float64 `json:"heading_deg"`
+	Exceeded   bool    `json:"exceeded,omitempty"`
+}
+
+// TrackRecord is the state of one multi-object track in a frame
+type TrackRecord struct {
+	ID    int    `json:"id"`
+	State string `json:"state"`
+	Rect  *Rect  `json:"rect"`
+
+	Filter     *FilterRecord `json:"filter,omitempty"`
+	Speed      *SpeedRecord  `json:"speed,omitempty"`
+	Trajectory []PointRecord `json:"trajectory,omitempty"`
+}
+
+// Writer writes per-frame tracking results as JSON lines
+type Writer struct {
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+
+	// Trajectories adds the trajectory of the target and every track to each frame record
+	Trajectories bool
+
+	transitions []TransitionRecord
+}
+
+// Create creates an export file at the given path
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create export file: %v", err)
+	}
+
+	buf := bufio.NewWriter(f)
+	return &Writer{
+		file: f,
+		buf:  buf,
+		enc:  json.NewEncoder(buf),
+	}, nil
+}
+
+// WriteFrame writes the tracking result of the current frame. Rectangles are
+// mapped from working frame coordinates back to the source frame.
+func (w *Writer) WriteFrame(state *types.AppState, trackingRect image.Rectangle, trackingSuccess bool, transform preprocess.Transform) error {
+	record := FrameRecord{
+		Frame:       state.FrameCount,
+		SourceIndex: state.SourceFrameIndex,
+		SourceName:  state.SourceFrameName,
+		TimestampMs: float64(state.FrameTimestamp) / float64(time.Millisecond),
+		State:       state.Mode.Current().String(),
+		Tracking:    state.Mode.Current().HasTarget(),
+		Success:     trackingSuccess,
+		Confidence:  state.TargetConfidence,
+		Label:       state.TargetLabel,
+	}
+	if !trackingRect.Empty() {
+		record.Rect = NewRect(transform.ToOriginal(trackingRect))
+	}
+	if !state.PredictedRect.Empty() {
+		record.Predicted = NewRect(transform.ToOriginal(state.PredictedRect))
+	}
+	if state.Mode.Current().HasTarget() {
+		record.Filter = NewFilterRecord(state.TargetFilter, transform)
+		record.Speed = NewSpeedRecord(state.TargetSpeed, state.MetersPerPixel, transform)
+		if w.Trajectories {
+			record.Trajectory = NewTrajectoryRecord(state.TargetHistory, transform)
+		}
+	}
+
+	for _, track := range state.Tracks {
+		if track.State == types.TrackDeleted {
+			continue
+		}
+		trackRecord := TrackRecord{
+			ID:    track.ID,
+			State: track.State.String(),
+			Rect:  NewRect(transform.ToOriginal(track.Rect)),
+
+			Filter: NewFilterRecord(track.Filter, transform),
+			Speed:  NewSpeedRecord(track.Speed, state.MetersPerPixel, transform),
+		}
+		if w.Trajectories {
+			trackRecord.Trajectory = NewTrajectoryRecord(track.History, transform)
+		}
+		record.Tracks = append(record.Tracks, trackRecord)
+	}
+
+	record.Transitions = w.transitions
+	w.transitions = nil
+
+	for _, crossing := range state.Crossings {
+		record.Crossings = append(record.Crossings, CrossingRecord{
+			Line:      crossing.Line,
+			Object:    crossing.Object,
+			Direction: string(crossing.Direction),
+		})
+	}
+	for _, line := range state.Tripwires {
+		record.Tripwires = append(record.Tripwires, TripwireRecord{Name: line.Name, In: line.In, Out: line.Out})
+	}
+
+	record.ZoneEvents, record.Zones = newZoneRecords(state)
+
+	return w.enc.Encode(record)
+}
+
+// WriteSummary writes the totals of the session after the last frame record
+func (w *Writer) WriteSummary(state *types.AppState) error {
+	var session SessionRecord
+	session.Frames = state.FrameCount
+	for _, line := range state.Tripwires {
+		session.Tripwires = append(session.Tripwires, TripwireRecord{Name: line.Name, In: line.In, Out: line.Out})
+	}
+	session.ZoneEvents, session.Zones = newZoneRecords(state)
+	return w.enc.Encode(SummaryRecord{Summary: session})
+}
+
+// newZoneRecords converts the zone events of the frame and the dwell statistics of every watch zone
+func newZoneRecords(state *types.AppState) ([]ZoneEventRecord, []ZoneRecord) {
+	var events []ZoneEventRecord
+	for _, event := range state.ZoneEvents {
+		events = append(events, ZoneEventRecord{
+			Zone:    event.Zone,
+			Object:  event.Object,
+			Event:   string(event.Kind),
+			DwellMs: milliseconds(event.Dwell),
+		})
+	}
+
+	var records []ZoneRecord
+	if state.Dwell != nil {
+		for _, zone := range state.Zones {
+			if zone.Kind == zones.Watch {
+				records = append(records, NewZoneRecord(zone.Name, state.Dwell.Zone(zone.Name)))
+			}
+		}
+	}
+	return events, records
+}
+
+// RecordTransition queues a tracking state change to be written with the next
+// frame record. It can be registered as a state machine listener.
+func (w *Writer) RecordTransition(t fsm.Transition) {
+	w.transitions = append(w.transitions, TransitionRecord{
+		From:   t.From.String(),
+		To:     t.To.String(),
+		Reason: t.Reason,
+	})
+}
+
+// Close flushes pending records and closes the export file
+func (w *Writer) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("error flushing export file: %v", err)
+	}
+	return w.file.Close()
+}
+
+// NewRect converts an image rectangle to an export rect
+func NewRect(r image.Rectangle) *Rect {
+	return &Rect{X: r.Min.X, Y: r.Min.Y, W: r.Dx(), H: r.Dy()}
+}
+
+// NewZoneRecord converts the dwell statistics of a watch zone
+func NewZoneRecord(name string, stats *zones.DwellStats) ZoneRecord {
+	return ZoneRecord{
+		Name:           name,
+		Occupancy:      len(stats.Present),
+		Loitering:      len(stats.Loitering),
+		Visits:         stats.Visits,
+		TotalDwellMs:   milliseconds(stats.Total),
+		AverageDwellMs: milliseconds(stats.Average()),
+		LongestDwellMs: milliseconds(stats.Longest),
+	}
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// NewTrajectoryRecord converts a trajectory to source frame coordinates
+func NewTrajectoryRecord(trajectory types.Trajectory, transform preprocess.Transform) []PointRecord {
+	points := make([]PointRecord, len(trajectory))
+	for i, point := range trajectory {
+		p := transform.PointToOriginal(point.Center)
+		points[i] = PointRecord{X: p.X, Y: p.Y, TimestampMs: float64(point.Time) / float64(time.Millisecond)}
+	}
+	return points
+}
+
+// NewSpeedRecord converts a speed to source frame coordinates, or returns nil
+// if it hasn't been measured yet. The scale is of the working frame, so m/s
+// don't depend on the transform.
+func NewSpeedRecord(speed types.Speed, metersPerPixel float64, transform preprocess.Transform) *SpeedRecord {
+	if !speed.Valid {
+		return nil
+	}
+
+	vx, vy := transform.VectorToOriginal(speed.VX, speed.VY)
+	original := types.Speed{VX: vx, VY: vy}
+	record := &SpeedRecord{
+		VX:         vx,
+		VY:         vy,
+		PxPerS:     original.PixelsPerSecond(),
+		HeadingDeg: original.Heading(),
+		Exceeded:   speed.Exceeded,
+	}
+	if metersPerPixel > 0 {
+		record.MPerS = speed.PixelsPerSecond() * metersPerPixel
+	}
+	return record
+}
+
+// NewFilterRecord converts a motion model to source frame coordinates, or returns nil if there is none
+func NewFilterRecord(filter *motion.Kalman, transform preprocess.Transform) *FilterRecord {
+	if filter == nil {
+		return nil
+	}
+
+	x := filter.State()
+	record := &FilterRecord{}
+	record.X, record.Y = transform.PointFToOriginal(x[0], x[1])
+	record.VX, record.VY = transform.VectorToOriginal(x[2], x[3])
+
+	// Transform the covariance with the linear part J of the mapping: C' = J C Jᵀ,
+	// where J applies the same 2×2 block to position and velocity
+	ax, ay := transform.VectorToOriginal(1, 0)
+	bx, by := transform.VectorToOriginal(0, 1)
+	j := [4][4]float64{
+		{ax, bx, 0, 0},
+		{ay, by, 0, 0},
+		{0, 0, ax, bx},
+		{0, 0, ay, by},
+	}
+	c := filter.Covariance()
+	for r := 0; r < 4; r++ {
+		for col := 0; col < 4; col++ {
+			var sum float64
+			for k := 0; k < 4; k++ {
+				for l := 0; l < 4; l++ {
+					sum += j[r][k] * c[k][l] * j[col][l]
+				}
+			}
+			record.Covariance[r][col] = sum
+		}
+	}
+	return record
+}
@@ -0,0 +1,141 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// State is the tracking mode of the application
+type State int
+
+const (
+	// Idle does nothing until the user picks a mode
+	Idle State = iota
+	// Searching looks for a moving object to start tracking automatically
+	Searching
+	// Selecting lets the user place the ROI by hand
+	Selecting
+	// Tracking follows a target that the tracker reports with confidence
+	Tracking
+	// Occluded keeps a target whose tracker is failing while recovery is attempted
+	Occluded
+	// Lost searches for a new target after the previous one could not be recovered,
+	// preferring candidates that look like the lost target
+	Lost
+)
+
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case Idle:
+		return "Idle"
+	case Searching:
+		return "Searching"
+	case Selecting:
+		return "Selecting"
+	case Tracking:
+		return "Tracking"
+	case Occluded:
+		return "Occluded"
+	case Lost:
+		return "Lost"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// HasTarget reports whether a target is held by the tracker
+func (s State) HasTarget() bool {
+	return s == Tracking || s == Occluded
+}
+
+// Acquiring reports whether auto-tracking is looking for a target
+func (s State) Acquiring() bool {
+	return s == Searching || s == Lost
+}
+
+// allowed lists the states each state may move to
+var allowed = map[State][]State{
+	Idle:      {Searching, Selecting},
+	Searching: {Idle, Selecting, Tracking},
+	Selecting: {Searching, Tracking},
+	Tracking:  {Searching, Selecting, Occluded, Lost},
+	Occluded:  {Searching, Selecting, Tracking, Lost},
+	Lost:      {Idle, Searching, Selecting, Tracking},
+}
+
+// ErrInvalidTransition is returned for a transition the state machine does not allow
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// CanTransition reports whether the state machine allows moving from one state to another
+func CanTransition(from, to State) bool {
+	for _, next := range allowed[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition is a change of state together with why it happened
+type Transition struct {
+	From   State
+	To     State
+	Reason string
+}
+
+// String returns a one-line description of the transition
+func (t Transition) String() string {
+	return fmt.Sprintf("%s -> %s: %s", t.From, t.To, t.Reason)
+}
+
+// Listener is called after every transition
+type Listener func(Transition)
+
+// Machine holds the current tracking state. The zero value starts in Idle.
+type Machine struct {
+	current   State
+	listeners []Listener
+}
+
+// Current returns the current state
+func (m *Machine) Current() State {
+	return m.current
+}
+
+// Is reports whether the current state is one of the given states
+func (m *Machine) Is(states ...State) bool {
+	for _, s := range states {
+		if m.current == s {
+			return true
+		}
+	}
+	return false
+}
+
+// OnTransition registers a listener for all following transitions
+func (m *Machine) OnTransition(listener Listener) {
+	m.listeners = append(m.listeners, listener)
+}
+
+// To moves the machine to the given state, logging the transition with its
+// reason and notifying listeners. Moving to the current state is a no-op;
+// a transition that isn't allowed leaves the state unchanged and returns ErrInvalidTransition.
+func (m *Machine) To(next State, reason string) error {
+	if next == m.current {
+		return nil
+	}
+	if !CanTransition(m.current, next) {
+		return fmt.Errorf("%w: %s -> %s (%s)", ErrInvalidTransition, m.current, next, reason)
+	}
+
+	t := Transition{From: m.current, To: next, Reason: reason}
+	m.current = next
+	log.Printf("Tracking state %s\n", t)
+
+	for _, listener := range m.listeners {
+		listener(t)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+var states = []State{Idle, Searching, Selecting, Tracking, Occluded, Lost}
+
+func TestTransitions(t *testing.T) {
+	tests := []struct {
+		from, to State
+		valid    bool
+	}{
+		{Idle, Searching, true},
+		{Idle, Selecting, true},
+		{Idle, Tracking, false},
+		{Idle, Occluded, false},
+		{Idle, Lost, false},
+
+		{Searching, Idle, true},
+		{Searching, Selecting, true},
+		{Searching, Tracking, true},
+		{Searching, Occluded, false},
+		{Searching, Lost, false},
+
+		{Selecting, Idle, false},
+		{Selecting, Searching, true},
+		{Selecting, Tracking, true},
+		{Selecting, Occluded, false},
+		{Selecting, Lost, false},
+
+		{Tracking, Idle, false},
+		{Tracking, Searching, true},
+		{Tracking, Selecting, true},
+		{Tracking, Occluded, true},
+		{Tracking, Lost, true},
+
+		{Occluded, Idle, false},
+		{Occluded, Searching, true},
+		{Occluded, Selecting, true},
+		{Occluded, Tracking, true},
+		{Occluded, Lost, true},
+
+		{Lost, Idle, true},
+		{Lost, Searching, true},
+		{Lost, Selecting, true},
+		{Lost, Tracking, true},
+		{Lost, Occluded, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from.String()+"->"+tt.to.String(), func(t *testing.T) {
+			m := &Machine{current: tt.from}
+			var notified []Transition
+			m.OnTransition(func(tr Transition) { notified = append(notified, tr) })
+
+			err := m.To(tt.to, "test")
+
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if m.Current() != tt.to {
+					t.Errorf("state = %s, want %s", m.Current(), tt.to)
+				}
+				want := Transition{From: tt.from, To: tt.to, Reason: "test"}
+				if len(notified) != 1 || notified[0] != want {
+					t.Errorf("listener got %v, want [%v]", notified, want)
+				}
+			} else {
+				if !errors.Is(err, ErrInvalidTransition) {
+					t.Fatalf("error = %v, want ErrInvalidTransition", err)
+				}
+				if m.Current() != tt.from {
+					t.Errorf("state changed to %s on invalid transition", m.Current())
+				}
+				if len(notified) != 0 {
+					t.Errorf("listener notified of invalid transition: %v", notified)
+				}
+			}
+		})
+	}
+}
+
+func TestTransitionTableIsComplete(t *testing.T) {
+	// A state added without an entry in the transition table could never be left
+	for _, from := range states {
+		if _, ok := allowed[from]; !ok {
+			t.Errorf("no transitions defined from %s", from)
+		}
+	}
+}
+
+func TestSelfTransitionIsNoop(t *testing.T) {
+	for _, s := range states {
+		m := &Machine{current: s}
+		notified := false
+		m.OnTransition(func(Transition) { notified = true })
+
+		if err := m.To(s, "again"); err != nil {
+			t.Errorf("%s -> %s: unexpected error %v", s, s, err)
+		}
+		if notified {
+			t.Errorf("%s -> %s: listener notified of self-transition", s, s)
+		}
+	}
+}
+
+func TestZeroValueStartsIdle(t *testing.T) {
+	var m Machine
+	if m.Current() != Idle {
+		t.Fatalf("zero value state = %s, want Idle", m.Current())
+	}
+	if err := m.To(Searching, "startup"); err != nil {
+		t.Fatalf("Idle -> Searching: %v", err)
+	}
+	if !m.Is(Searching, Lost) {
+		t.Errorf("Is(Searching, Lost) = false in %s", m.Current())
+	}
+}
+
+func TestStatePredicates(t *testing.T) {
+	tests := []struct {
+		state     State
+		hasTarget bool
+		acquiring bool
+	}{
+		{Idle, false, false},
+		{Searching, false, true},
+		{Selecting, false, false},
+		{Tracking, true, false},
+		{Occluded, true, false},
+		{Lost, false, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.state.HasTarget(); got != tt.hasTarget {
+			t.Errorf("%s.HasTarget() = %v, want %v", tt.state, got, tt.hasTarget)
+		}
+		if got := tt.state.Acquiring(); got != tt.acquiring {
+			t.Errorf("%s.Acquiring() = %v, want %v", tt.state, got, tt.acquiring)
+		}
+	}
+}
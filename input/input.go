@@ -6,21 +6,23 @@ import (
 
 	"gocv.io/x/gocv"
 
+	"tracker/fsm"
 	"tracker/recording"
 	"tracker/tracking"
 	"tracker/types"
 )
 
+// Limits of the motion trail length adjustable by key
+const (
+	minTrajectoryLength = 8
+	maxTrajectoryLength = 1024
+)
+
 // HandleEscapeKey handles the ESC key press based on current mode
 func HandleEscapeKey(state *types.AppState) bool {
-	if state.ROISelectionMode {
-		// Cancel ROI selection
-		state.ROISelectionMode = false
-		// Re-enable auto-tracking when cancelling ROI selection
-		if !state.TrackingEnabled {
-			state.AutoTrackingEnabled = true
-		}
-		log.Println("ROI selection cancelled")
+	if state.Mode.Is(fsm.Selecting) {
+		// Cancel ROI selection and go back to auto-tracking
+		tracking.CancelROISelection(state, "ROI selection cancelled")
 		return false // Don't quit
 	}
 
@@ -37,10 +39,8 @@ func HandleMainKeys(key int, state *types.AppState, frame gocv.Mat, trackingConf
 		return true
 
 	case 's': // 's' to start live ROI selection
-		if !state.ROISelectionMode {
-			state.AutoTrackingEnabled = false
-			state.TrackingEnabled = false
-			state.ROISelectionMode = true
+		if !state.Mode.Is(fsm.Selecting) {
+			tracking.StartROISelection(state)
 			// Set initial ROI center to image center
 			state.ROICenterX = frame.Cols() / 2
 			state.ROICenterY = frame.Rows() / 2
@@ -48,23 +48,76 @@ func HandleMainKeys(key int, state *types.AppState, frame gocv.Mat, trackingConf
 		}
 
 	case 'a': // 'a' to toggle auto-tracking
-		if !state.ROISelectionMode {
-			if state.AutoTrackingEnabled {
-				state.AutoTrackingEnabled = false
-				state.TrackingEnabled = false
-				log.Println("Auto-tracking disabled")
+		if !state.Mode.Is(fsm.Selecting) {
+			if state.Mode.Current().Acquiring() {
+				tracking.DisableAutoTracking(state)
 			} else {
 				tracking.EnableAutoTracking(state)
-				log.Println("Auto-tracking enabled")
 			}
 		}
 
+	case 'm': // 'm' to toggle multi-object tracking
+		state.MultiTrackingEnabled = !state.MultiTrackingEnabled
+		if state.MultiTrackingEnabled {
+			log.Println("Multi-object tracking enabled")
+		} else {
+			tracking.ResetMultiTracking(state)
+			log.Println("Multi-object tracking disabled")
+		}
+
+	case 't': // 't' to cycle through tracking algorithms
+		if err := tracking.SwitchTracker(state, frame, tracking.NextTrackerName(state.TrackerName), trackingConfig); err != nil {
+			log.Printf("Tracker switch failed: %v\n", err)
+		}
+
+	case 'p': // 'p' to cycle through auto-tracking target selection policies
+		state.TargetPolicy = tracking.NextPolicyName(state.TargetPolicy)
+		log.Printf("Target selection policy: %s\n", state.TargetPolicy)
+
+	case 'h': // 'h' to toggle motion trails
+		state.ShowTrajectories = !state.ShowTrajectories
+		if state.ShowTrajectories {
+			log.Println("Motion trails shown")
+		} else {
+			log.Println("Motion trails hidden")
+		}
+
+	case '[', ']': // '[' / ']' to shorten or lengthen motion trails
+		length := state.TrajectoryLength / 2
+		if key == ']' {
+			length = state.TrajectoryLength * 2
+		}
+		if length >= minTrajectoryLength && length <= maxTrajectoryLength {
+			tracking.SetTrajectoryLength(state, length)
+			log.Printf("Motion trail length: %d points\n", length)
+		}
+
+	case 'z': // 'z' to draw a detection zone
+		if !state.Mode.Is(fsm.Selecting) {
+			StartZoneDrawing(state)
+		}
+
+	case 'x': // 'x' to delete the last detection zone
+		DeleteLastZone(state, trackingConfig)
+
+	case 'l': // 'l' to draw a tripwire line
+		if !state.Mode.Is(fsm.Selecting) {
+			StartLineDrawing(state)
+		}
+
+	case 'L': // 'L' to delete the last tripwire line
+		DeleteLastTripwire(state, trackingConfig)
+
+	case 'k': // 'k' to measure the pixel-to-world scale for speeds
+		if !state.Mode.Is(fsm.Selecting) {
+			StartMeasuring(state, trackingConfig)
+		}
+
 	case 'r': // 'r' to reset tracking
 		tracking.ResetTracking(state)
-		log.Println("Tracking reset. Auto-tracking enabled")
 
-	case 'v': // 'v' to toggle video recording
-		if err := recording.ToggleRecording(state, frame, videoConfig); err != nil {
+	case 'v': // 'v' to toggle video recording of the source frames
+		if err := recording.ToggleRecording(state, state.SourceFrameSize, videoConfig); err != nil {
 			log.Printf("Recording error: %v\n", err)
 		}
 
@@ -82,7 +135,7 @@ func HandleMainKeys(key int, state *types.AppState, frame gocv.Mat, trackingConf
 
 // HandleROIKeys handles keyboard input during ROI selection mode
 func HandleROIKeys(key int, state *types.AppState, frame gocv.Mat) {
-	if !state.ROISelectionMode {
+	if !state.Mode.Is(fsm.Selecting) {
 		return
 	}
 
@@ -149,11 +202,8 @@ func HandleROIKeys(key int, state *types.AppState, frame gocv.Mat) {
 
 		roi := image.Rect(x1, y1, x2, y2)
 
-		if tracking.InitializeTracking(state, frame, roi) {
-			log.Println("Manual tracking started!")
-		} else {
-			log.Println("Failed to initialize tracker")
-			state.ROISelectionMode = false
+		if !tracking.InitializeTracking(state, frame, roi) {
+			tracking.CancelROISelection(state, "failed to initialize tracker on selected ROI")
 		}
 	}
 }
@@ -168,6 +218,20 @@ func InitializeROISelection(state *types.AppState) {
 
 // ProcessInput processes all keyboard input for the application
 func ProcessInput(key int, state *types.AppState, frame gocv.Mat, trackingConfig types.TrackingConfig, videoConfig types.VideoConfig) bool {
+	// Scale measurement, line and zone drawing take all keys until they're done
+	if state.Measuring {
+		HandleMeasureKeys(key, state)
+		return false
+	}
+	if state.LineDrawing {
+		HandleLineKeys(key, state)
+		return false
+	}
+	if state.ZoneDrawing {
+		HandleZoneKeys(key, state, trackingConfig)
+		return false
+	}
+
 	// Handle ESC key first
 	if key == 27 {
 		return HandleEscapeKey(state)
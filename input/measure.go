@@ -0,0 +1,47 @@
+package input
+
+import (
+	"image"
+	"log"
+	"math"
+
+	"tracker/types"
+)
+
+// StartMeasuring starts measuring the pixel-to-world scale from two clicked
+// points a known reference length apart
+func StartMeasuring(state *types.AppState, trackingConfig types.TrackingConfig) {
+	state.Measuring = true
+	state.MeasurePoints = nil
+	log.Printf("Scale measurement mode. Click both ends of an object %.2f m long, ESC to cancel.\n", trackingConfig.ReferenceLength)
+}
+
+// HandleMeasureKeys handles keyboard input while the scale is being measured
+func HandleMeasureKeys(key int, state *types.AppState) {
+	if key == 27 { // ESC - keep the previous scale
+		state.Measuring = false
+		state.MeasurePoints = nil
+		log.Println("Scale measurement cancelled")
+	}
+}
+
+// addMeasurePoint records a clicked point and sets the scale once both ends
+// of the reference are known
+func addMeasurePoint(p image.Point, state *types.AppState, trackingConfig types.TrackingConfig) {
+	state.MeasurePoints = append(state.MeasurePoints, p)
+	if len(state.MeasurePoints) < 2 {
+		return
+	}
+
+	a, b := state.MeasurePoints[0], state.MeasurePoints[1]
+	state.Measuring = false
+	state.MeasurePoints = nil
+
+	pixels := math.Hypot(float64(b.X-a.X), float64(b.Y-a.Y))
+	if pixels < 1 {
+		log.Println("Scale measurement failed: the points are too close")
+		return
+	}
+	state.MetersPerPixel = trackingConfig.ReferenceLength / pixels
+	log.Printf("Scale set to %.4f m/px (%.2f m over %.0f px)\n", state.MetersPerPixel, trackingConfig.ReferenceLength, pixels)
+}
@@ -0,0 +1,70 @@
+package input
+
+import (
+	"image"
+	"log"
+
+	"tracker/tripwire"
+	"tracker/types"
+)
+
+// StartLineDrawing starts drawing a new tripwire line
+func StartLineDrawing(state *types.AppState) {
+	state.LineDrawing = true
+	state.LineDraft = nil
+	log.Println("Tripwire drawing mode. Click the start and end of the line, ESC to cancel.")
+}
+
+// HandleLineKeys handles keyboard input while a tripwire line is being drawn
+func HandleLineKeys(key int, state *types.AppState) {
+	if key == 27 { // ESC - discard the line
+		state.LineDrawing = false
+		state.LineDraft = nil
+		log.Println("Tripwire drawing cancelled")
+	}
+}
+
+// addLinePoint records a clicked point and adds the line once both ends are known
+func addLinePoint(p image.Point, state *types.AppState, trackingConfig types.TrackingConfig) {
+	state.LineDraft = append(state.LineDraft, p)
+	if len(state.LineDraft) < 2 {
+		return
+	}
+
+	line := tripwire.Line{
+		Name:  tripwire.UniqueName(state.Tripwires),
+		Start: state.LineDraft[0],
+		End:   state.LineDraft[1],
+	}
+	state.LineDrawing = false
+	state.LineDraft = nil
+	if line.Start == line.End {
+		log.Println("A tripwire needs two different points")
+		return
+	}
+
+	state.Tripwires = append(state.Tripwires, line)
+	log.Printf("Tripwire %s added from (%d,%d) to (%d,%d)\n", line.Name, line.Start.X, line.Start.Y, line.End.X, line.End.Y)
+	saveTripwires(state, trackingConfig)
+}
+
+// DeleteLastTripwire removes the most recently added tripwire line
+func DeleteLastTripwire(state *types.AppState, trackingConfig types.TrackingConfig) {
+	if len(state.Tripwires) == 0 {
+		return
+	}
+	removed := state.Tripwires[len(state.Tripwires)-1]
+	state.Tripwires = state.Tripwires[:len(state.Tripwires)-1]
+	log.Printf("Tripwire %s deleted (in %d, out %d)\n", removed.Name, removed.In, removed.Out)
+	saveTripwires(state, trackingConfig)
+}
+
+// saveTripwires writes the tripwire lines to the configured tripwires file
+func saveTripwires(state *types.AppState, trackingConfig types.TrackingConfig) {
+	if trackingConfig.TripwiresFile == "" {
+		return
+	}
+	if err := tripwire.Save(trackingConfig.TripwiresFile, state.Tripwires); err != nil {
+		log.Printf("Error saving tripwires: %v\n", err)
+	}
+}
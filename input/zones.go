@@ -0,0 +1,97 @@
+package input
+
+import (
+	"image"
+	"log"
+
+	"tracker/types"
+	"tracker/zones"
+)
+
+// mouseLeftButtonDown is OpenCV's EVENT_LBUTTONDOWN
+const mouseLeftButtonDown = 1
+
+// HandleMouse adds a point to the scale measurement, the tripwire line or the
+// zone being drawn on a left click. It can be registered as the window mouse handler; the
+// preview shows the working frame, so window coordinates are working frame
+// coordinates.
+func HandleMouse(event, x, y int, state *types.AppState, trackingConfig types.TrackingConfig) {
+	if event != mouseLeftButtonDown {
+		return
+	}
+	switch {
+	case state.Measuring:
+		addMeasurePoint(image.Pt(x, y), state, trackingConfig)
+	case state.LineDrawing:
+		addLinePoint(image.Pt(x, y), state, trackingConfig)
+	case state.ZoneDrawing:
+		state.ZoneDraft = append(state.ZoneDraft, image.Pt(x, y))
+	}
+}
+
+// StartZoneDrawing starts drawing a new zone, excluding by default
+func StartZoneDrawing(state *types.AppState) {
+	state.ZoneDrawing = true
+	state.ZoneDraft = nil
+	state.ZoneDraftKind = zones.Exclude
+	log.Println("Zone drawing mode. Click to add points, i/e/w for include/exclude/watch, Backspace to undo, ENTER to save, ESC to cancel.")
+}
+
+// HandleZoneKeys handles keyboard input while a zone is being drawn
+func HandleZoneKeys(key int, state *types.AppState, trackingConfig types.TrackingConfig) {
+	switch key {
+	case 'i':
+		state.ZoneDraftKind = zones.Include
+	case 'e':
+		state.ZoneDraftKind = zones.Exclude
+	case 'w':
+		state.ZoneDraftKind = zones.Watch
+	case 8, 127: // Backspace - remove the last point
+		if len(state.ZoneDraft) > 0 {
+			state.ZoneDraft = state.ZoneDraft[:len(state.ZoneDraft)-1]
+		}
+	case 13: // ENTER - finish the zone
+		if len(state.ZoneDraft) < 3 {
+			log.Println("A zone needs at least 3 points")
+			return
+		}
+		zone := zones.Zone{
+			Name:   zones.UniqueName(state.Zones, state.ZoneDraftKind),
+			Kind:   state.ZoneDraftKind,
+			Points: state.ZoneDraft,
+		}
+		state.Zones = append(state.Zones, zone)
+		state.ZoneDrawing = false
+		state.ZoneDraft = nil
+		log.Printf("Zone %s added with %d points\n", zone.Name, len(zone.Points))
+		saveZones(state, trackingConfig)
+	case 27: // ESC - discard the zone
+		state.ZoneDrawing = false
+		state.ZoneDraft = nil
+		log.Println("Zone drawing cancelled")
+	}
+}
+
+// DeleteLastZone removes the most recently added zone
+func DeleteLastZone(state *types.AppState, trackingConfig types.TrackingConfig) {
+	if len(state.Zones) == 0 {
+		return
+	}
+	removed := state.Zones[len(state.Zones)-1]
+	state.Zones = state.Zones[:len(state.Zones)-1]
+	if state.Dwell != nil {
+		state.Dwell.Remove(removed.Name)
+	}
+	log.Printf("Zone %s deleted\n", removed.Name)
+	saveZones(state, trackingConfig)
+}
+
+// saveZones writes the zones to the configured zones file
+func saveZones(state *types.AppState, trackingConfig types.TrackingConfig) {
+	if trackingConfig.ZonesFile == "" {
+		return
+	}
+	if err := zones.Save(trackingConfig.ZonesFile, state.Zones); err != nil {
+		log.Printf("Error saving zones: %v\n", err)
+	}
+}
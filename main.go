@@ -1,71 +1,358 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"image"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"gocv.io/x/gocv"
-	"gocv.io/x/gocv/contrib"
 
+	"tracker/background"
+	"tracker/detector"
+	"tracker/downscale"
+	"tracker/egomotion"
+	"tracker/export"
+	"tracker/fsm"
 	"tracker/input"
+	"tracker/motion"
+	"tracker/preprocess"
 	"tracker/recording"
+	"tracker/source"
 	"tracker/tracking"
+	"tracker/tripwire"
 	"tracker/types"
 	"tracker/ui"
+	"tracker/zones"
 )
 
+// app holds everything the capture→track→record pipeline needs
+type app struct {
+	src          source.FrameSource
+	preprocessor *preprocess.Preprocessor
+	state        *types.AppState
+	exporter     *export.Writer
+	debugLogger  *types.DebugLogger
+
+	// working is the preprocessed frame that tracking and the UI operate on
+	working   gocv.Mat
+	transform preprocess.Transform
+
+	trackingConfig types.TrackingConfig
+	videoConfig    types.VideoConfig
+	uiConfig       types.UIConfig
+	runConfig      types.RunConfig
+
+	sourceFrames int
+}
+
 func main() {
-	// Initialize video capture
-	vc, err := gocv.OpenVideoCapture(0)
+	// Parse command line flags
+	sourceConfig := types.DefaultSourceConfig()
+	runConfig := types.DefaultRunConfig()
+	videoConfig := types.DefaultVideoConfig()
+	preprocessConfig := types.DefaultPreprocessConfig()
+	trackingConfig := types.DefaultTrackingConfig()
+	flag.StringVar(&sourceConfig.Source, "source", sourceConfig.Source, "camera device index, stream URL (rtsp://, http://), video file path or image sequence directory")
+	flag.BoolVar(&sourceConfig.Loop, "loop", sourceConfig.Loop, "restart video files and image sequences when they end")
+	flag.IntVar(&sourceConfig.CaptureBuffer, "capture-buffer", sourceConfig.CaptureBuffer, "frames queued between capture and tracking for cameras and streams, oldest dropped first (0 captures synchronously)")
+	flag.DurationVar(&sourceConfig.ReconnectDelay, "reconnect-delay", sourceConfig.ReconnectDelay, "initial delay before reconnecting a lost stream")
+	flag.DurationVar(&sourceConfig.ReconnectMaxDelay, "reconnect-max-delay", sourceConfig.ReconnectMaxDelay, "maximum delay between stream reconnect attempts")
+	flag.Float64Var(&sourceConfig.FPS, "fps", sourceConfig.FPS, "frame rate of image sequence sources")
+	flag.IntVar(&sourceConfig.StartIndex, "start-index", sourceConfig.StartIndex, "first frame number of an image sequence")
+	flag.IntVar(&sourceConfig.EndIndex, "end-index", sourceConfig.EndIndex, "last frame number of an image sequence (-1 for all)")
+	flag.BoolVar(&runConfig.Headless, "headless", runConfig.Headless, "run without a window (stop with SIGINT or at end of stream)")
+	flag.Float64Var(&runConfig.HeadlessFPS, "headless-fps", runConfig.HeadlessFPS, "frame rate limit in headless mode (0 uses the source rate, negative disables pacing)")
+	flag.StringVar(&trackingConfig.TrackerAlgorithm, "tracker", trackingConfig.TrackerAlgorithm, "tracking algorithm: "+strings.Join(tracking.TrackerNames(), ", "))
+	flag.StringVar(&trackingConfig.TargetPolicy, "policy", trackingConfig.TargetPolicy, "auto-tracking target selection: "+strings.Join(tracking.PolicyNames(), ", "))
+	multiTracking := flag.Bool("multi", false, "track all moving objects with persistent IDs")
+	flag.StringVar(&trackingConfig.BackgroundSubtractor, "bg", trackingConfig.BackgroundSubtractor, "background subtraction algorithm: MOG2 or KNN")
+	flag.IntVar(&trackingConfig.BackgroundHistory, "bg-history", trackingConfig.BackgroundHistory, "frames in the background model history")
+	flag.Float64Var(&trackingConfig.BackgroundVarThreshold, "bg-var-threshold", trackingConfig.BackgroundVarThreshold, "squared Mahalanobis distance above which a pixel is foreground (MOG2 only)")
+	flag.Float64Var(&trackingConfig.BackgroundDist2Threshold, "bg-dist2-threshold", trackingConfig.BackgroundDist2Threshold, "squared distance above which a pixel is foreground (KNN only)")
+	flag.BoolVar(&trackingConfig.BackgroundDetectShadows, "bg-shadows", trackingConfig.BackgroundDetectShadows, "mark shadows separately in the foreground mask")
+	flag.Float64Var(&trackingConfig.BackgroundLearningRate, "bg-learning-rate", trackingConfig.BackgroundLearningRate, "background model learning rate 0-1 (negative for automatic, MOG2 only)")
+	flag.Float64Var(&trackingConfig.MaskShadowThreshold, "mask-shadow-threshold", trackingConfig.MaskShadowThreshold, "drop foreground mask pixels below this value, e.g. 200 removes shadows (0 disables)")
+	flag.IntVar(&trackingConfig.MaskErodeIterations, "mask-erode", trackingConfig.MaskErodeIterations, "erosion iterations that shrink foreground blobs (0 disables)")
+	flag.IntVar(&trackingConfig.MaskDilateIterations, "mask-dilate", trackingConfig.MaskDilateIterations, "dilation iterations that grow foreground blobs after erosion (0 disables)")
+	flag.IntVar(&trackingConfig.MaskOpenSize, "mask-open", trackingConfig.MaskOpenSize, "kernel size of the morphological opening that removes mask speckles (0 disables)")
+	flag.IntVar(&trackingConfig.MaskCloseSize, "mask-close", trackingConfig.MaskCloseSize, "kernel size of the morphological closing that fills mask holes (0 disables)")
+	flag.IntVar(&trackingConfig.MaskMedianSize, "mask-median", trackingConfig.MaskMedianSize, "median blur aperture for the foreground mask (0 disables)")
+	flag.BoolVar(&videoConfig.RecordOnStart, "record", videoConfig.RecordOnStart, "start recording with the first frame")
+	flag.BoolVar(&preprocessConfig.Mirror, "mirror", preprocessConfig.Mirror, "mirror frames horizontally")
+	flag.IntVar(&preprocessConfig.Rotate, "rotate", preprocessConfig.Rotate, "rotate frames clockwise by 0, 90, 180 or 270 degrees")
+	flag.Func("crop", "crop the mirrored/rotated frame to x,y,w,h", func(s string) error {
+		rect, err := preprocess.ParseRect(s)
+		preprocessConfig.Crop = rect
+		return err
+	})
+	flag.Func("resize", "resize frames to WxH before tracking (0 for either side keeps the aspect ratio)", func(s string) error {
+		var err error
+		preprocessConfig.ResizeWidth, preprocessConfig.ResizeHeight, err = preprocess.ParseSize(s)
+		return err
+	})
+	flag.StringVar(&trackingConfig.Detector, "detector", trackingConfig.Detector, "object detector for auto-tracking: dnn or cascade (empty uses motion only)")
+	flag.BoolVar(&trackingConfig.DetectorCombine, "detector-combine", trackingConfig.DetectorCombine, "use moving objects alongside detected objects instead of replacing them")
+	flag.IntVar(&trackingConfig.DetectorInterval, "detector-interval", trackingConfig.DetectorInterval, "run the object detector every N frames")
+	flag.StringVar(&trackingConfig.DetectorModel, "detector-model", trackingConfig.DetectorModel, "YOLO-style ONNX model file for the dnn detector")
+	flag.StringVar(&trackingConfig.DetectorClassesFile, "detector-classes-file", trackingConfig.DetectorClassesFile, "class names of the dnn model, one per line (default COCO)")
+	flag.Func("detector-classes", "comma separated classes auto-tracking may pick, e.g. person,dog (default all)", func(s string) error {
+		trackingConfig.DetectorClasses = strings.Split(s, ",")
+		return nil
+	})
+	flag.Float64Var(&trackingConfig.DetectorConfidence, "detector-confidence", trackingConfig.DetectorConfidence, "minimum detection confidence 0-1")
+	flag.Float64Var(&trackingConfig.DetectorNMS, "detector-nms", trackingConfig.DetectorNMS, "IoU threshold of non-maximum suppression")
+	flag.StringVar(&trackingConfig.CascadeFile, "cascade", trackingConfig.CascadeFile, "Haar/LBP cascade XML file for the cascade detector, e.g. haarcascade_frontalface_default.xml")
+	flag.Float64Var(&trackingConfig.MetersPerPixel, "meters-per-pixel", trackingConfig.MetersPerPixel, "pixel-to-world scale of the working frame for speeds in m/s (0 shows px/s until measured with k)")
+	flag.Float64Var(&trackingConfig.ReferenceLength, "reference-length", trackingConfig.ReferenceLength, "length in meters of the reference object clicked to measure the scale")
+	flag.Float64Var(&trackingConfig.SpeedLimit, "speed-limit", trackingConfig.SpeedLimit, "alert when a target moves faster than this many m/s (0 disables)")
+	flag.BoolVar(&trackingConfig.CameraMotion, "camera-motion", trackingConfig.CameraMotion, "compensate global camera motion for handheld or panning cameras")
+	flag.Float64Var(&trackingConfig.CameraMotionReinitShift, "camera-motion-reinit-shift", trackingConfig.CameraMotionReinitShift, "restart the tracker on the moved target once the camera has shifted it this many px")
+	flag.Func("detection-scale", "run background subtraction and object detection at this fraction of the working resolution, e.g. 0.25 or 1/4", func(s string) error {
+		var err error
+		trackingConfig.DetectionScale, err = downscale.ParseScale(s)
+		return err
+	})
+	flag.Func("tracking-scale", "run the tracker at this fraction of the working resolution, e.g. 0.5 or 1/2", func(s string) error {
+		var err error
+		trackingConfig.TrackingScale, err = downscale.ParseScale(s)
+		return err
+	})
+	flag.StringVar(&trackingConfig.ZonesFile, "zones", trackingConfig.ZonesFile, "JSON file the include/exclude detection zones are loaded from and saved to (empty keeps zones in memory)")
+	flag.DurationVar(&trackingConfig.LoiterTime, "loiter-time", trackingConfig.LoiterTime, "alert when a target stays in a watch zone this long (0 disables)")
+	flag.StringVar(&trackingConfig.TripwiresFile, "tripwires", trackingConfig.TripwiresFile, "JSON file the tripwire lines are loaded from and saved to (empty keeps lines in memory)")
+	flag.Float64Var(&trackingConfig.TripwireMinDistance, "tripwire-distance", trackingConfig.TripwireMinDistance, "px a center must get past a tripwire before the crossing counts")
+	flag.DurationVar(&trackingConfig.TripwireCooldown, "tripwire-cooldown", trackingConfig.TripwireCooldown, "ignore crossings by the same object this long after one was counted")
+	exportPath := flag.String("export", "", "write per-frame tracking results to this JSON lines file")
+	exportTrajectories := flag.Bool("export-trajectories", false, "include the trajectory of the target and every track in each exported frame")
+	flag.Parse()
+
+	// Stop cleanly on SIGINT/SIGTERM so recordings and exports are finalized
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Initialize frame source
+	src, err := source.Open(sourceConfig)
 	if err != nil {
-		log.Fatal("failed to open video capture device:", err)
+		log.Fatal("failed to open frame source:", err)
 	}
-	defer func() { _ = vc.Close() }()
+	defer func() { _ = src.Close() }()
 
-	// Initialize window
-	w := gocv.NewWindow("tracker")
-	defer func() { _ = w.Close() }()
+	// Initialize frame preprocessing
+	preprocessor, err := preprocess.NewPreprocessor(preprocessConfig)
+	if err != nil {
+		log.Fatal("invalid preprocessing configuration:", err)
+	}
+	defer func() { _ = preprocessor.Close() }()
+	preprocess.LogConfig(preprocessConfig)
+	if trackingConfig.DetectionScale < 1 || trackingConfig.TrackingScale < 1 {
+		log.Printf("Detection at %.2fx and tracking at %.2fx of the working resolution", trackingConfig.DetectionScale, trackingConfig.TrackingScale)
+	}
+
+	// Initialize result export
+	var exporter *export.Writer
+	if *exportPath != "" {
+		exporter, err = export.Create(*exportPath)
+		if err != nil {
+			log.Fatal("failed to create export file:", err)
+		}
+		exporter.Trajectories = *exportTrajectories
+		defer func() {
+			if err := exporter.Close(); err != nil {
+				log.Printf("Error closing export file: %v", err)
+			}
+		}()
+	}
 
 	// Initialize tracker
-	tracker := contrib.NewTrackerCSRT()
-	defer func() { _ = tracker.Close() }()
+	tracker, trackerName, err := tracking.NewTracker(trackingConfig.TrackerAlgorithm, trackingConfig.TrackingScale)
+	if err != nil {
+		log.Fatal("failed to create tracker:", err)
+	}
+	targetPolicy, err := tracking.ParsePolicy(trackingConfig.TargetPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Initialize frame matrix
-	frame := gocv.NewMat()
-	defer func() { _ = frame.Close() }()
+	// Initialize background subtraction
+	backSub, err := background.New(trackingConfig)
+	if err != nil {
+		log.Fatal("failed to create background subtractor:", err)
+	}
+
+	// Initialize object detection
+	objectDetector, err := detector.New(trackingConfig)
+	if err != nil {
+		log.Fatal("failed to create object detector:", err)
+	}
+	if objectDetector != nil {
+		defer func() { _ = objectDetector.Close() }()
+	}
+
+	// Initialize camera motion compensation
+	var cameraMotion types.CameraMotionEstimator
+	if trackingConfig.CameraMotion {
+		cameraMotion = egomotion.New(trackingConfig)
+		defer func() { _ = cameraMotion.Close() }()
+	}
 
 	// Initialize application state
 	state := &types.AppState{
-		Tracker:             tracker,
-		AutoTrackingEnabled: true,
-		BackSub:             gocv.NewBackgroundSubtractorMOG2(),
-		FgMask:              gocv.NewMat(),
+		Tracker:              tracker,
+		TrackerName:          trackerName,
+		TargetPolicy:         targetPolicy,
+		TrajectoryLength:     trackingConfig.TrajectoryLength,
+		ShowTrajectories:     true,
+		MetersPerPixel:       trackingConfig.MetersPerPixel,
+		MultiTrackingEnabled: *multiTracking,
+		BackSub:              backSub,
+		Detector:             objectDetector,
+		CameraMotion:         cameraMotion,
+		Dwell:                zones.NewDwell(trackingConfig.LoiterTime),
+		FrameMotion:          motion.Identity(),
+		BackgroundAlignment:  motion.Identity(),
+		DetectionFrame:       gocv.NewMat(),
+		FgMask:               gocv.NewMat(),
+		TargetPatch:          gocv.NewMat(),
 	}
+	// The tracker can be swapped at runtime, so close whichever one is active at exit
+	defer func() { _ = state.Tracker.Close() }()
 	defer func() { _ = state.BackSub.Close() }()
+	defer func() { _ = state.DetectionFrame.Close() }()
 	defer func() { _ = state.FgMask.Close() }()
+	defer func() { _ = state.TargetPatch.Close() }()
 
 	// Initialize ROI selection defaults
 	input.InitializeROISelection(state)
 
+	// Load detection zones
+	if trackingConfig.ZonesFile != "" {
+		state.Zones, err = zones.Load(trackingConfig.ZonesFile)
+		if err != nil {
+			log.Fatal("failed to load zones:", err)
+		}
+		if len(state.Zones) > 0 {
+			log.Printf("Loaded %d detection zones from %s", len(state.Zones), trackingConfig.ZonesFile)
+		}
+	}
+
+	// Load tripwire lines
+	if trackingConfig.TripwiresFile != "" {
+		state.Tripwires, err = tripwire.Load(trackingConfig.TripwiresFile)
+		if err != nil {
+			log.Fatal("failed to load tripwires:", err)
+		}
+		if len(state.Tripwires) > 0 {
+			log.Printf("Loaded %d tripwires from %s", len(state.Tripwires), trackingConfig.TripwiresFile)
+		}
+	}
+
+	// Record state changes with the exported frames and start looking for a target
+	if exporter != nil {
+		state.Mode.OnTransition(exporter.RecordTransition)
+	}
+	if err := state.Mode.To(fsm.Searching, "auto-tracking on startup"); err != nil {
+		log.Fatal(err)
+	}
+
 	// Load configurations
-	trackingConfig := types.DefaultTrackingConfig()
-	videoConfig := types.DefaultVideoConfig()
+	if fps := src.FPS(); fps > 0 {
+		// Record at the rate the source delivers frames
+		videoConfig.FPS = fps
+	}
 	uiConfig := types.DefaultUIConfig()
-	
+
 	// Initialize debug logger
 	debugLogger := types.NewDebugLogger(state, uiConfig.MaxDebugLogs)
-	
+
 	// Set debug logger to capture standard log output
 	debugLogger.SetAsLogOutput()
 	defer debugLogger.RestoreOriginalLogOutput()
 
-	// Print startup instructions
-	ui.PrintStartupInstructions()
+	a := &app{
+		src:            src,
+		preprocessor:   preprocessor,
+		working:        gocv.NewMat(),
+		state:          state,
+		exporter:       exporter,
+		debugLogger:    debugLogger,
+		trackingConfig: trackingConfig,
+		videoConfig:    videoConfig,
+		uiConfig:       uiConfig,
+		runConfig:      runConfig,
+	}
+	defer func() { _ = a.working.Close() }()
+
+	if runConfig.Headless {
+		a.runHeadless(ctx)
+	} else {
+		// Print startup instructions
+		ui.PrintStartupInstructions()
+		a.runWindowed(ctx)
+	}
+
+	// Cleanup recording on exit
+	recording.CleanupRecording(state)
+	a.finishSession()
+}
+
+// finishSession ends open zone stays, saves the tripwire totals and writes
+// the session summary to the export
+func (a *app) finishSession() {
+	state := a.state
+	tracking.CloseZoneDwell(state)
+	for _, line := range state.Tripwires {
+		log.Printf("Tripwire %s: in %d, out %d", line.Name, line.In, line.Out)
+	}
+	if len(state.Tripwires) > 0 && a.trackingConfig.TripwiresFile != "" {
+		path := tripwire.CountsPath(a.trackingConfig.TripwiresFile)
+		if err := tripwire.AppendCounts(path, state.Tripwires, time.Now()); err != nil {
+			log.Printf("Error saving tripwire counts: %v", err)
+		} else {
+			log.Printf("Tripwire counts saved to %s", path)
+		}
+	}
+
+	if a.exporter != nil {
+		if err := a.exporter.WriteSummary(state); err != nil {
+			log.Printf("Error writing session summary: %v", err)
+		}
+	}
+}
+
+// runWindowed runs the pipeline with a preview window and keyboard control
+func (a *app) runWindowed(ctx context.Context) {
+	// Initialize window
+	w := gocv.NewWindow("tracker")
+	defer func() { _ = w.Close() }()
+	w.SetMouseHandler(func(event, x, y, _ int, _ interface{}) {
+		input.HandleMouse(event, x, y, a.state, a.trackingConfig)
+	}, nil)
+
+	// Initialize frame matrix
+	frame := gocv.NewMat()
+	defer func() { _ = frame.Close() }()
 
-	// Main loop
-	for {
-		// Read frame from camera
-		if ok := vc.Read(&frame); !ok {
+	for ctx.Err() == nil {
+		// Read frame from source
+		err := a.readFrame(&frame)
+		if a.state.SourceReconnecting {
+			// Keep tracking state and the UI alive while the source comes back
+			showReconnecting(w, &a.working, a.src.Size(), a.state, a.uiConfig)
+			if shouldQuit := input.ProcessInput(w.WaitKey(15), a.state, a.working, a.trackingConfig, a.videoConfig); shouldQuit {
+				break
+			}
+			continue
+		}
+		if err != nil {
+			logReadError(err)
 			break
 		}
 
@@ -73,61 +360,211 @@ func main() {
 			continue
 		}
 
-		// Mirror the image horizontally
-		if err := gocv.Flip(frame, &frame, 1); err != nil {
-			log.Printf("Error flipping image: %v", err)
+		trackingRect, trackingSuccess, ok := a.processFrame(frame)
+		if !ok {
 			continue
 		}
 
-		state.FrameCount++
-		
-		// Debug logging for frame processing (every 60 frames to avoid spam)
-		if state.FrameCount%60 == 0 {
-			debugLogger.Log(fmt.Sprintf("Frame %d processed", state.FrameCount))
+		// Render all UI elements
+		ui.RenderFrame(&a.working, a.state, trackingRect, trackingSuccess, a.uiConfig)
+
+		// Display frame
+		_ = w.IMShow(a.working)
+		key := w.WaitKey(15)
+
+		// Process input and check for quit
+		if shouldQuit := input.ProcessInput(key, a.state, a.working, a.trackingConfig, a.videoConfig); shouldQuit {
+			break
 		}
+	}
+}
+
+// runHeadless runs the pipeline without a window until interrupted or the source ends
+func (a *app) runHeadless(ctx context.Context) {
+	frame := gocv.NewMat()
+	defer func() { _ = frame.Close() }()
+
+	// Without WaitKey the loop would spin as fast as the source allows, so pace it explicitly
+	interval := a.frameInterval()
+	var ticker *time.Ticker
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		defer ticker.Stop()
+	}
+
+	log.Printf("Running headless (frame interval %s)", interval)
 
-		// Process auto-tracking
-		tracking.ProcessAutoTracking(state, frame, trackingConfig)
+	for ctx.Err() == nil {
+		if ticker != nil {
+			select {
+			case <-ctx.Done():
+				continue
+			case <-ticker.C:
+			}
+		}
 
-		// Enable auto-tracking by default if nothing is active
-		if !state.TrackingEnabled && !state.AutoTrackingEnabled && !state.ROISelectionMode {
-			state.AutoTrackingEnabled = true
-			debugLogger.Log("Auto-tracking re-enabled (default state)")
+		err := a.readFrame(&frame)
+		if a.state.SourceReconnecting {
+			if ticker == nil {
+				// Avoid busy-waiting on a source that isn't delivering frames
+				time.Sleep(15 * time.Millisecond)
+			}
+			continue
+		}
+		if err != nil {
+			logReadError(err)
+			break
 		}
 
-		// Process tracking and get current rectangle
-		trackingRect := tracking.ProcessTracking(state, frame, trackingConfig)
-		trackingSuccess := state.TrackingEnabled && !trackingRect.Empty() && state.TrackingFailureCount == 0
-		
-		// Debug logging for tracking state (less frequent to avoid spam)
-		if state.TrackingEnabled && state.FrameCount%30 == 0 {
-			debugLogger.Log(fmt.Sprintf("Tracking: %dx%d at (%d,%d)", 
-				trackingRect.Dx(), trackingRect.Dy(), trackingRect.Min.X, trackingRect.Min.Y))
-		} else if state.AutoTrackingEnabled && state.FrameCount%120 == 0 {
-			debugLogger.Log("Auto-tracking: searching for objects...")
+		if frame.Empty() {
+			continue
 		}
 
-		// Write frame to video if recording
-		if err := recording.WriteFrame(state, frame); err != nil {
-			log.Printf("Error writing video frame: %v", err)
-		} else if state.IsRecording && state.FrameCount%30 == 0 {
-			// Log recording status every 30 frames to avoid spam
-			debugLogger.Log("Recording active")
+		trackingRect, _, ok := a.processFrame(frame)
+		if !ok {
+			continue
 		}
 
-		// Render all UI elements
-		ui.RenderFrame(&frame, state, trackingRect, trackingSuccess, uiConfig)
+		// Without an on-screen status, report progress periodically
+		if a.state.FrameCount%300 == 0 {
+			if a.state.Mode.Current().HasTarget() {
+				log.Printf("Frame %d: tracking %dx%d at (%d,%d), confidence %.2f", a.state.FrameCount,
+					trackingRect.Dx(), trackingRect.Dy(), trackingRect.Min.X, trackingRect.Min.Y, a.state.TargetConfidence)
+			} else {
+				log.Printf("Frame %d: no target (%s)", a.state.FrameCount, a.state.Mode.Current())
+			}
+		}
+	}
 
-		// Display frame
-		_ = w.IMShow(frame)
-		key := w.WaitKey(15)
+	if ctx.Err() != nil {
+		log.Println("Interrupted, shutting down")
+	}
+}
 
-		// Process input and check for quit
-		if shouldQuit := input.ProcessInput(key, state, frame, trackingConfig, videoConfig); shouldQuit {
-			break
+// frameInterval returns the pacing interval for headless mode, or 0 to run unpaced
+func (a *app) frameInterval() time.Duration {
+	fps := a.runConfig.HeadlessFPS
+	if fps == 0 {
+		fps = a.src.FPS()
+	}
+	if fps <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / fps)
+}
+
+// readFrame reads the next frame and updates the source status in the application state
+func (a *app) readFrame(frame *gocv.Mat) error {
+	err := a.src.Read(frame)
+	a.state.SourceReconnecting = errors.Is(err, source.ErrReconnecting)
+	if async, ok := a.src.(*source.AsyncSource); ok {
+		a.state.DroppedFrames = async.Dropped()
+	}
+	return err
+}
+
+// logReadError reports why the source stopped delivering frames
+func logReadError(err error) {
+	if errors.Is(err, source.ErrEndOfStream) {
+		log.Println("End of stream reached")
+	} else {
+		log.Printf("Error reading frame: %v", err)
+	}
+}
+
+// processFrame preprocesses a captured frame into the working frame and runs
+// tracking, export and recording on it. It returns false if the frame had to be skipped.
+func (a *app) processFrame(frame gocv.Mat) (image.Rectangle, bool, bool) {
+	state := a.state
+
+	// Apply mirror/rotate/crop/resize before tracking
+	transform, err := a.preprocessor.Apply(frame, &a.working)
+	if err != nil {
+		log.Printf("Error preprocessing frame: %v", err)
+		return image.Rectangle{}, false, false
+	}
+	a.transform = transform
+	working := a.working
+
+	state.FrameCount++
+	state.FrameTimestamp = a.src.Timestamp()
+
+	// Tie the frame back to its origin in the source
+	if labeler, ok := a.src.(source.FrameLabeler); ok {
+		state.SourceFrameIndex = labeler.FrameIndex()
+		state.SourceFrameName = labeler.FrameName()
+	} else {
+		state.SourceFrameIndex = a.sourceFrames
+	}
+	a.sourceFrames++
+	state.SourceFrameSize = image.Pt(frame.Cols(), frame.Rows())
+
+	// Debug logging for frame processing (every 60 frames to avoid spam)
+	if state.FrameCount%60 == 0 {
+		a.debugLogger.Log(fmt.Sprintf("Frame %d processed at %s", state.FrameCount, state.FrameTimestamp.Truncate(time.Millisecond)))
+	}
+
+	// Process auto-tracking
+	tracking.BeginFrame(state)
+	tracking.CompensateCameraMotion(state, working, a.trackingConfig)
+	tracking.ProcessAutoTracking(state, working, a.trackingConfig)
+
+	// Process tracking and get current rectangle
+	trackingRect := tracking.ProcessTracking(state, working, a.trackingConfig)
+	trackingSuccess := state.Mode.Is(fsm.Tracking) && !trackingRect.Empty()
+
+	// Follow every moving object when multi-object tracking is enabled
+	tracking.ProcessMultiTracking(state, working, a.trackingConfig)
+
+	// Count tripwire crossings and zone dwell times of the target and the tracks
+	tracking.ProcessTripwires(state, a.trackingConfig)
+	tracking.ProcessZoneDwell(state)
+
+	// Debug logging for tracking state (less frequent to avoid spam)
+	if state.Mode.Current().HasTarget() && state.FrameCount%30 == 0 {
+		a.debugLogger.Log(fmt.Sprintf("Tracking: %dx%d at (%d,%d), confidence %.2f",
+			trackingRect.Dx(), trackingRect.Dy(), trackingRect.Min.X, trackingRect.Min.Y, state.TargetConfidence))
+	} else if state.Mode.Current().Acquiring() && state.FrameCount%120 == 0 {
+		a.debugLogger.Log("Auto-tracking: searching for objects...")
+	}
+
+	// Export tracking result for this frame in source frame coordinates
+	if a.exporter != nil {
+		if err := a.exporter.WriteFrame(state, trackingRect, trackingSuccess, a.transform); err != nil {
+			log.Printf("Error exporting frame: %v", err)
 		}
 	}
 
-	// Cleanup recording on exit
-	recording.CleanupRecording(state)
+	// Start recording with the first frame if requested
+	if a.videoConfig.RecordOnStart {
+		a.videoConfig.RecordOnStart = false
+		if err := recording.StartRecording(state, state.SourceFrameSize, a.videoConfig); err != nil {
+			log.Printf("Recording error: %v\n", err)
+		}
+	}
+
+	// Write the source frame to video if recording, so exported coordinates line up with it
+	if err := recording.WriteFrame(state, frame); err != nil {
+		log.Printf("Error writing video frame: %v", err)
+	} else if state.IsRecording && state.FrameCount%30 == 0 {
+		// Log recording status every 30 frames to avoid spam
+		a.debugLogger.Log("Recording active")
+	}
+
+	return trackingRect, trackingSuccess, true
+}
+
+// showReconnecting displays a blank frame with the reconnect status while the source is unavailable
+func showReconnecting(w *gocv.Window, frame *gocv.Mat, size image.Point, state *types.AppState, config types.UIConfig) {
+	if frame.Empty() {
+		if size.X <= 0 || size.Y <= 0 {
+			size = image.Pt(640, 480)
+		}
+		_ = frame.Close()
+		*frame = gocv.NewMatWithSize(size.Y, size.X, gocv.MatTypeCV8UC3)
+	}
+	frame.SetTo(gocv.NewScalar(0, 0, 0, 0))
+
+	ui.RenderFrame(frame, state, image.Rectangle{}, false, config)
+	_ = w.IMShow(*frame)
 }
@@ -0,0 +1,76 @@
+package motion
+
+import (
+	"image"
+	"math"
+)
+
+// Affine is a 2×3 affine transform [a b tx; c d ty] mapping image points,
+// such as the global motion of the camera between two frames
+type Affine [6]float64
+
+// Identity returns the transform that leaves points where they are
+func Identity() Affine {
+	return Affine{1, 0, 0, 0, 1, 0}
+}
+
+// ApplyF maps a point
+func (m Affine) ApplyF(x, y float64) (float64, float64) {
+	return m[0]*x + m[1]*y + m[2], m[3]*x + m[4]*y + m[5]
+}
+
+// Apply maps a pixel point, rounding to the nearest pixel
+func (m Affine) Apply(p image.Point) image.Point {
+	x, y := m.ApplyF(float64(p.X), float64(p.Y))
+	return image.Pt(int(math.Round(x)), int(math.Round(y)))
+}
+
+// Vector maps a direction or velocity, which only the linear part affects
+func (m Affine) Vector(vx, vy float64) (float64, float64) {
+	return m[0]*vx + m[1]*vy, m[3]*vx + m[4]*vy
+}
+
+// Scale returns the uniform scale factor of the transform
+func (m Affine) Scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[4] - m[1]*m[3]))
+}
+
+// Rect maps a bounding box by moving its center and scaling its size, which
+// keeps it axis-aligned instead of growing it around a rotation
+func (m Affine) Rect(r image.Rectangle) image.Rectangle {
+	if r.Empty() {
+		return r
+	}
+	cx, cy := m.ApplyF(float64(r.Min.X+r.Max.X)/2, float64(r.Min.Y+r.Max.Y)/2)
+	s := m.Scale()
+	w, h := float64(r.Dx())*s, float64(r.Dy())*s
+	return image.Rect(
+		int(math.Round(cx-w/2)), int(math.Round(cy-h/2)),
+		int(math.Round(cx+w/2)), int(math.Round(cy+h/2)),
+	)
+}
+
+// Compose returns the transform that applies n first and then m
+func (m Affine) Compose(n Affine) Affine {
+	return Affine{
+		m[0]*n[0] + m[1]*n[3], m[0]*n[1] + m[1]*n[4], m[0]*n[2] + m[1]*n[5] + m[2],
+		m[3]*n[0] + m[4]*n[3], m[3]*n[1] + m[4]*n[4], m[3]*n[2] + m[4]*n[5] + m[5],
+	}
+}
+
+// Invert returns the inverse transform, or the identity if m is singular
+func (m Affine) Invert() Affine {
+	det := m[0]*m[4] - m[1]*m[3]
+	if det == 0 {
+		return Identity()
+	}
+	a, b := m[4]/det, -m[1]/det
+	c, d := -m[3]/det, m[0]/det
+	return Affine{a, b, -(a*m[2] + b*m[5]), c, d, -(c*m[2] + d*m[5])}
+}
+
+// Shift returns how far the transform moves a point
+func (m Affine) Shift(p image.Point) float64 {
+	x, y := m.ApplyF(float64(p.X), float64(p.Y))
+	return math.Hypot(x-float64(p.X), y-float64(p.Y))
+}
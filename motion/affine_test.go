@@ -0,0 +1,90 @@
+package motion
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// rotation returns a rotation by angle radians about the origin, scaled by s and then shifted by (tx, ty)
+func rotation(angle, s, tx, ty float64) Affine {
+	sin, cos := math.Sincos(angle)
+	return Affine{s * cos, -s * sin, tx, s * sin, s * cos, ty}
+}
+
+func TestAffineComposeInvert(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Affine
+	}{
+		{"identity", Identity()},
+		{"translation", Affine{1, 0, 12, 0, 1, -7}},
+		{"rotation and scale", rotation(0.3, 1.2, 40, -15)},
+		{"shear", Affine{1, 0.5, 3, 0.2, 1, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, got := range []Affine{tt.m.Compose(tt.m.Invert()), tt.m.Invert().Compose(tt.m)} {
+				for i, want := range Identity() {
+					if math.Abs(got[i]-want) > 1e-9 {
+						t.Fatalf("m composed with its inverse = %v, want identity", got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAffineInvertSingular(t *testing.T) {
+	if got := (Affine{1, 2, 3, 2, 4, 6}).Invert(); got != Identity() {
+		t.Errorf("Invert() of a singular transform = %v, want identity", got)
+	}
+}
+
+func TestAffineCompose(t *testing.T) {
+	shift := Affine{1, 0, 10, 0, 1, 0}
+	scale := Affine{2, 0, 0, 0, 2, 0}
+
+	// Compose applies its argument first
+	if got, want := scale.Compose(shift).Apply(image.Pt(1, 1)), image.Pt(22, 2); got != want {
+		t.Errorf("scale after shift maps (1,1) to %v, want %v", got, want)
+	}
+	if got, want := shift.Compose(scale).Apply(image.Pt(1, 1)), image.Pt(12, 2); got != want {
+		t.Errorf("shift after scale maps (1,1) to %v, want %v", got, want)
+	}
+}
+
+func TestAffineRect(t *testing.T) {
+	rect := image.Rect(10, 20, 30, 60)
+
+	tests := []struct {
+		name string
+		m    Affine
+		want image.Rectangle
+	}{
+		{"identity", Identity(), rect},
+		{"translation", Affine{1, 0, 5, 0, 1, -5}, image.Rect(15, 15, 35, 55)},
+		{"scale about the origin", Affine{2, 0, 0, 0, 2, 0}, image.Rect(20, 40, 60, 120)},
+		{"quarter turn keeps the box upright", rotation(math.Pi/2, 1, 0, 0), image.Rect(-50, 0, -30, 40)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.Rect(rect); got != tt.want {
+				t.Errorf("Rect(%v) = %v, want %v", rect, got, tt.want)
+			}
+		})
+	}
+
+	if got := (Affine{1, 0, 5, 0, 1, 5}).Rect(image.Rectangle{}); !got.Empty() {
+		t.Errorf("Rect of an empty rectangle = %v, want empty", got)
+	}
+}
+
+func TestAffineShift(t *testing.T) {
+	m := Affine{1, 0, 3, 0, 1, 4}
+	if got := m.Shift(image.Pt(100, 100)); math.Abs(got-5) > 1e-9 {
+		t.Errorf("Shift() = %v, want 5", got)
+	}
+}
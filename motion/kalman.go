@@ -0,0 +1,188 @@
+package motion
+
+import (
+	"image"
+	"math"
+	"time"
+)
+
+// Kalman is a constant-velocity Kalman filter over a target's center point.
+// The state is [x, y, vx, vy] in pixels and pixels per second.
+type Kalman struct {
+	x [4]float64
+	p [4][4]float64
+
+	// accelNoise is the standard deviation of unmodelled acceleration in px/s²
+	accelNoise float64
+	// measurementNoise is the standard deviation of a position measurement in px
+	measurementNoise float64
+
+	timestamp time.Duration
+}
+
+// initialVelocityStd is the assumed velocity uncertainty of a new target in px/s
+const initialVelocityStd = 200.0
+
+// NewKalman creates a filter for a target first seen at center at the given frame timestamp
+func NewKalman(center image.Point, timestamp time.Duration, accelNoise, measurementNoise float64) *Kalman {
+	k := &Kalman{
+		x:                [4]float64{float64(center.X), float64(center.Y), 0, 0},
+		accelNoise:       accelNoise,
+		measurementNoise: measurementNoise,
+		timestamp:        timestamp,
+	}
+
+	r := measurementNoise * measurementNoise
+	v := initialVelocityStd * initialVelocityStd
+	k.p[0][0], k.p[1][1] = r, r
+	k.p[2][2], k.p[3][3] = v, v
+	return k
+}
+
+// Predict advances the filter to the given frame timestamp
+func (k *Kalman) Predict(timestamp time.Duration) {
+	dt := (timestamp - k.timestamp).Seconds()
+	if dt <= 0 {
+		return
+	}
+	k.timestamp = timestamp
+
+	// x = F x
+	k.x[0] += k.x[2] * dt
+	k.x[1] += k.x[3] * dt
+
+	// P = F P Fᵀ + Q
+	f := [4][4]float64{
+		{1, 0, dt, 0},
+		{0, 1, 0, dt},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	}
+	p := mul(mul(f, k.p), transpose(f))
+
+	// Discrete white noise acceleration model
+	q := k.accelNoise * k.accelNoise
+	dt2 := dt * dt
+	dt3 := dt2 * dt / 2
+	dt4 := dt2 * dt2 / 4
+	p[0][0] += q * dt4
+	p[1][1] += q * dt4
+	p[0][2] += q * dt3
+	p[2][0] += q * dt3
+	p[1][3] += q * dt3
+	p[3][1] += q * dt3
+	p[2][2] += q * dt2
+	p[3][3] += q * dt2
+	k.p = p
+}
+
+// Correct updates the filter with a measured center point
+func (k *Kalman) Correct(center image.Point) {
+	r := k.measurementNoise * k.measurementNoise
+
+	// Innovation y = z - H x, with H selecting the position
+	y0 := float64(center.X) - k.x[0]
+	y1 := float64(center.Y) - k.x[1]
+
+	// S = H P Hᵀ + R
+	s00, s01 := k.p[0][0]+r, k.p[0][1]
+	s10, s11 := k.p[1][0], k.p[1][1]+r
+	det := s00*s11 - s01*s10
+	if det == 0 {
+		return
+	}
+	i00, i01 := s11/det, -s01/det
+	i10, i11 := -s10/det, s00/det
+
+	// K = P Hᵀ S⁻¹
+	var gain [4][2]float64
+	for i := 0; i < 4; i++ {
+		gain[i][0] = k.p[i][0]*i00 + k.p[i][1]*i10
+		gain[i][1] = k.p[i][0]*i01 + k.p[i][1]*i11
+	}
+
+	// x = x + K y
+	for i := 0; i < 4; i++ {
+		k.x[i] += gain[i][0]*y0 + gain[i][1]*y1
+	}
+
+	// P = (I - K H) P
+	var p [4][4]float64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			p[i][j] = k.p[i][j] - gain[i][0]*k.p[0][j] - gain[i][1]*k.p[1][j]
+		}
+	}
+	k.p = p
+}
+
+// Warp moves the filter along with the image, e.g. to follow camera motion.
+// Position and velocity are mapped by m, the covariance with its linear part.
+func (k *Kalman) Warp(m Affine) {
+	k.x[0], k.x[1] = m.ApplyF(k.x[0], k.x[1])
+	k.x[2], k.x[3] = m.Vector(k.x[2], k.x[3])
+
+	// P = J P Jᵀ, where J applies the linear part to position and velocity
+	j := [4][4]float64{
+		{m[0], m[1], 0, 0},
+		{m[3], m[4], 0, 0},
+		{0, 0, m[0], m[1]},
+		{0, 0, m[3], m[4]},
+	}
+	k.p = mul(mul(j, k.p), transpose(j))
+}
+
+// Position returns the estimated center point
+func (k *Kalman) Position() image.Point {
+	return image.Pt(int(math.Round(k.x[0])), int(math.Round(k.x[1])))
+}
+
+// Velocity returns the estimated velocity in px/s
+func (k *Kalman) Velocity() (float64, float64) {
+	return k.x[2], k.x[3]
+}
+
+// State returns the full state vector [x, y, vx, vy]
+func (k *Kalman) State() [4]float64 {
+	return k.x
+}
+
+// Covariance returns the state covariance matrix
+func (k *Kalman) Covariance() [4][4]float64 {
+	return k.p
+}
+
+// PositionStd returns the standard deviation of the position estimate in px
+func (k *Kalman) PositionStd() float64 {
+	return math.Sqrt((k.p[0][0] + k.p[1][1]) / 2)
+}
+
+// PredictRect returns rect moved so that it is centered on the estimated position
+func (k *Kalman) PredictRect(rect image.Rectangle) image.Rectangle {
+	pos := k.Position()
+	return rect.Add(pos.Sub(image.Pt(rect.Min.X+rect.Dx()/2, rect.Min.Y+rect.Dy()/2)))
+}
+
+// mul multiplies two 4×4 matrices
+func mul(a, b [4][4]float64) [4][4]float64 {
+	var c [4][4]float64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 4; k++ {
+				c[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return c
+}
+
+// transpose returns the transpose of a 4×4 matrix
+func transpose(a [4][4]float64) [4][4]float64 {
+	var t [4][4]float64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			t[i][j] = a[j][i]
+		}
+	}
+	return t
+}
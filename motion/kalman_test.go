@@ -0,0 +1,60 @@
+package motion
+
+import (
+	"image"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestKalmanHoldsConstantVelocity(t *testing.T) {
+	const vx, vy = 60.0, -30.0
+	frame := time.Second / 30
+	at := func(i int) image.Point {
+		s := (time.Duration(i) * frame).Seconds()
+		return image.Pt(100+int(math.Round(vx*s)), 200+int(math.Round(vy*s)))
+	}
+
+	k := NewKalman(at(0), 0, 50, 2)
+	for i := 1; i <= 90; i++ {
+		k.Predict(time.Duration(i) * frame)
+		k.Correct(at(i))
+	}
+
+	gotVX, gotVY := k.Velocity()
+	if math.Abs(gotVX-vx) > 2 || math.Abs(gotVY-vy) > 2 {
+		t.Fatalf("Velocity() = (%.1f, %.1f), want (%.0f, %.0f)", gotVX, gotVY, vx, vy)
+	}
+
+	// Without measurements the filter keeps moving at the estimated velocity
+	k.Predict(100 * frame)
+	got, want := k.Position(), at(100)
+	if d := got.Sub(want); d.X*d.X+d.Y*d.Y > 4 {
+		t.Errorf("predicted position = %v, want %v", got, want)
+	}
+	if gotVX2, gotVY2 := k.Velocity(); gotVX2 != gotVX || gotVY2 != gotVY {
+		t.Errorf("Predict changed the velocity to (%.1f, %.1f)", gotVX2, gotVY2)
+	}
+}
+
+func TestKalmanPredictIgnoresPastTimestamps(t *testing.T) {
+	k := NewKalman(image.Pt(10, 10), time.Second, 50, 2)
+	before := k.State()
+	k.Predict(time.Second)
+	k.Predict(time.Second / 2)
+	if k.State() != before {
+		t.Errorf("State() = %v after predicting into the past, want %v", k.State(), before)
+	}
+}
+
+func TestKalmanWarp(t *testing.T) {
+	k := NewKalman(image.Pt(10, 20), 0, 50, 2)
+	k.x[2], k.x[3] = 5, 0
+
+	// Shift by (3, -4) and scale by 2 about the origin
+	k.Warp(Affine{2, 0, 3, 0, 2, -4})
+
+	if got, want := k.State(), [4]float64{23, 36, 10, 0}; got != want {
+		t.Errorf("State() = %v, want %v", got, want)
+	}
+}
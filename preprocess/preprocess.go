@@ -0,0 +1,132 @@
+package preprocess
+
+import (
+	"fmt"
+	"image"
+	"log"
+
+	"gocv.io/x/gocv"
+
+	"tracker/types"
+)
+
+// Preprocessor applies the configured mirror, rotate, crop and resize steps to
+// captured frames, producing the working frame that tracking runs on
+type Preprocessor struct {
+	config  types.PreprocessConfig
+	flipped gocv.Mat
+	rotated gocv.Mat
+}
+
+// NewPreprocessor creates a preprocessor for the given configuration
+func NewPreprocessor(config types.PreprocessConfig) (*Preprocessor, error) {
+	switch config.Rotate {
+	case 0, 90, 180, 270:
+	default:
+		return nil, fmt.Errorf("unsupported rotation %d, must be 0, 90, 180 or 270", config.Rotate)
+	}
+
+	return &Preprocessor{
+		config:  config,
+		flipped: gocv.NewMat(),
+		rotated: gocv.NewMat(),
+	}, nil
+}
+
+// Apply writes the preprocessed version of src into dst and returns the
+// transform that maps working frame coordinates back to src
+func (p *Preprocessor) Apply(src gocv.Mat, dst *gocv.Mat) (Transform, error) {
+	t := Transform{
+		sourceSize: image.Pt(src.Cols(), src.Rows()),
+		mirror:     p.config.Mirror,
+		rotate:     p.config.Rotate,
+		scaleX:     1,
+		scaleY:     1,
+	}
+
+	current := src
+
+	// Mirror the image horizontally
+	if p.config.Mirror {
+		if err := gocv.Flip(current, &p.flipped, 1); err != nil {
+			return t, fmt.Errorf("error flipping image: %v", err)
+		}
+		current = p.flipped
+	}
+
+	// Rotate clockwise
+	if flag, ok := rotateFlag(p.config.Rotate); ok {
+		if err := gocv.Rotate(current, &p.rotated, flag); err != nil {
+			return t, fmt.Errorf("error rotating image: %v", err)
+		}
+		current = p.rotated
+	}
+
+	// Crop to the configured region of the oriented frame
+	bounds := image.Rect(0, 0, current.Cols(), current.Rows())
+	t.crop = bounds
+	if !p.config.Crop.Empty() {
+		t.crop = p.config.Crop.Intersect(bounds)
+		if t.crop.Empty() {
+			return t, fmt.Errorf("crop %v lies outside the %dx%d frame", p.config.Crop, bounds.Dx(), bounds.Dy())
+		}
+	}
+	region := current.Region(t.crop)
+	defer func() { _ = region.Close() }()
+
+	// Resize to the working resolution
+	size := p.workingSize(t.crop.Size())
+	if size == t.crop.Size() {
+		if err := region.CopyTo(dst); err != nil {
+			return t, fmt.Errorf("error copying image: %v", err)
+		}
+		return t, nil
+	}
+
+	if err := gocv.Resize(region, dst, size, 0, 0, gocv.InterpolationArea); err != nil {
+		return t, fmt.Errorf("error resizing image: %v", err)
+	}
+	t.scaleX = float64(t.crop.Dx()) / float64(size.X)
+	t.scaleY = float64(t.crop.Dy()) / float64(size.Y)
+	return t, nil
+}
+
+// workingSize returns the resize target for a cropped frame of the given size.
+// A zero width or height is derived from the other one to keep the aspect ratio.
+func (p *Preprocessor) workingSize(cropped image.Point) image.Point {
+	width, height := p.config.ResizeWidth, p.config.ResizeHeight
+	switch {
+	case width <= 0 && height <= 0:
+		return cropped
+	case width <= 0:
+		width = cropped.X * height / cropped.Y
+	case height <= 0:
+		height = cropped.Y * width / cropped.X
+	}
+	return image.Pt(width, height)
+}
+
+// Close releases the scratch buffers
+func (p *Preprocessor) Close() error {
+	_ = p.flipped.Close()
+	return p.rotated.Close()
+}
+
+// rotateFlag converts a clockwise angle to the OpenCV rotation code
+func rotateFlag(degrees int) (gocv.RotateFlag, bool) {
+	switch degrees {
+	case 90:
+		return gocv.Rotate90Clockwise, true
+	case 180:
+		return gocv.Rotate180Clockwise, true
+	case 270:
+		return gocv.Rotate90CounterClockwise, true
+	}
+	return 0, false
+}
+
+// LogConfig prints the active preprocessing steps
+func LogConfig(config types.PreprocessConfig) {
+	log.Printf("Preprocessing: mirror=%t rotate=%d crop=%v resize=%dx%d",
+		config.Mirror, config.Rotate, config.Crop, config.ResizeWidth, config.ResizeHeight)
+}
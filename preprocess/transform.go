@@ -0,0 +1,124 @@
+package preprocess
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Transform describes how a working frame was derived from the source frame.
+// The zero value is the identity transform.
+type Transform struct {
+	sourceSize image.Point
+	mirror     bool
+	rotate     int
+	crop       image.Rectangle
+	scaleX     float64
+	scaleY     float64
+}
+
+// ToOriginal maps a rectangle in working frame coordinates to source frame coordinates
+func (t Transform) ToOriginal(r image.Rectangle) image.Rectangle {
+	if r.Empty() {
+		return r
+	}
+	x0, y0 := t.pointToOriginal(float64(r.Min.X), float64(r.Min.Y))
+	x1, y1 := t.pointToOriginal(float64(r.Max.X), float64(r.Max.Y))
+	return image.Rect(round(x0), round(y0), round(x1), round(y1))
+}
+
+// PointToOriginal maps a point in working frame coordinates to source frame coordinates
+func (t Transform) PointToOriginal(p image.Point) image.Point {
+	x, y := t.pointToOriginal(float64(p.X), float64(p.Y))
+	return image.Pt(round(x), round(y))
+}
+
+// PointFToOriginal maps a sub-pixel point in working frame coordinates to source frame coordinates
+func (t Transform) PointFToOriginal(x, y float64) (float64, float64) {
+	return t.pointToOriginal(x, y)
+}
+
+// VectorToOriginal maps a displacement or velocity in working frame coordinates
+// to source frame coordinates. Only the linear part of the transform applies.
+func (t Transform) VectorToOriginal(dx, dy float64) (float64, float64) {
+	x0, y0 := t.pointToOriginal(0, 0)
+	x1, y1 := t.pointToOriginal(dx, dy)
+	return x1 - x0, y1 - y0
+}
+
+// pointToOriginal undoes resize, crop, rotation and mirroring, in that order
+func (t Transform) pointToOriginal(x, y float64) (float64, float64) {
+	if t.sourceSize == (image.Point{}) {
+		return x, y
+	}
+
+	// Undo resize and crop
+	x = x*t.scaleX + float64(t.crop.Min.X)
+	y = y*t.scaleY + float64(t.crop.Min.Y)
+
+	// Undo clockwise rotation
+	w, h := float64(t.sourceSize.X), float64(t.sourceSize.Y)
+	switch t.rotate {
+	case 90:
+		x, y = y, h-x
+	case 180:
+		x, y = w-x, h-y
+	case 270:
+		x, y = w-y, x
+	}
+
+	// Undo horizontal mirroring
+	if t.mirror {
+		x = w - x
+	}
+	return x, y
+}
+
+// round converts a coordinate to the nearest pixel
+func round(v float64) int {
+	return int(math.Round(v))
+}
+
+// ParseRect parses a rectangle given as "x,y,w,h"
+func ParseRect(s string) (image.Rectangle, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("invalid rectangle %q, expected x,y,w,h", s)
+	}
+
+	var v [4]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("invalid rectangle %q: %v", s, err)
+		}
+		v[i] = n
+	}
+	if v[2] <= 0 || v[3] <= 0 {
+		return image.Rectangle{}, fmt.Errorf("invalid rectangle %q, width and height must be positive", s)
+	}
+	return image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3]), nil
+}
+
+// ParseSize parses a size given as "WxH". Either side may be 0 to keep the aspect ratio.
+func ParseSize(s string) (int, int, error) {
+	parts := strings.Split(strings.ToLower(s), "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid size %q, expected WxH", s)
+	}
+
+	w, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid size %q: %v", s, err)
+	}
+	h, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid size %q: %v", s, err)
+	}
+	if w < 0 || h < 0 || (w == 0 && h == 0) {
+		return 0, 0, fmt.Errorf("invalid size %q", s)
+	}
+	return w, h, nil
+}
@@ -0,0 +1,141 @@
+package preprocess
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// source is an odd sized source frame, so rounding of the resize shows up
+var source = image.Pt(101, 61)
+
+func TestTransformToOriginal(t *testing.T) {
+	full := image.Rect(0, 0, source.X, source.Y)
+	rotatedFull := image.Rect(0, 0, source.Y, source.X)
+
+	tests := []struct {
+		name      string
+		transform Transform
+		rect      image.Rectangle
+		wantRect  image.Rectangle
+		point     image.Point
+		wantPoint image.Point
+	}{
+		{
+			name:     "zero value",
+			rect:     image.Rect(10, 20, 30, 50),
+			wantRect: image.Rect(10, 20, 30, 50),
+			point:    image.Pt(10, 20), wantPoint: image.Pt(10, 20),
+		},
+		{
+			name:      "no steps",
+			transform: Transform{sourceSize: source, crop: full, scaleX: 1, scaleY: 1},
+			rect:      image.Rect(10, 20, 30, 50),
+			wantRect:  image.Rect(10, 20, 30, 50),
+			point:     image.Pt(10, 20), wantPoint: image.Pt(10, 20),
+		},
+		{
+			name:      "mirror",
+			transform: Transform{sourceSize: source, mirror: true, crop: full, scaleX: 1, scaleY: 1},
+			rect:      image.Rect(10, 20, 30, 50),
+			wantRect:  image.Rect(71, 20, 91, 50),
+			point:     image.Pt(10, 20), wantPoint: image.Pt(91, 20),
+		},
+		{
+			name:      "rotate 90",
+			transform: Transform{sourceSize: source, rotate: 90, crop: rotatedFull, scaleX: 1, scaleY: 1},
+			rect:      image.Rect(10, 20, 30, 50),
+			wantRect:  image.Rect(20, 31, 50, 51),
+			point:     image.Pt(10, 20), wantPoint: image.Pt(20, 51),
+		},
+		{
+			name:      "rotate 180",
+			transform: Transform{sourceSize: source, rotate: 180, crop: full, scaleX: 1, scaleY: 1},
+			rect:      image.Rect(10, 20, 30, 50),
+			wantRect:  image.Rect(71, 11, 91, 41),
+			point:     image.Pt(10, 20), wantPoint: image.Pt(91, 41),
+		},
+		{
+			name:      "rotate 270",
+			transform: Transform{sourceSize: source, rotate: 270, crop: rotatedFull, scaleX: 1, scaleY: 1},
+			rect:      image.Rect(10, 20, 30, 50),
+			wantRect:  image.Rect(51, 10, 81, 30),
+			point:     image.Pt(10, 20), wantPoint: image.Pt(81, 10),
+		},
+		{
+			name:      "crop",
+			transform: Transform{sourceSize: source, crop: image.Rect(5, 7, 85, 57), scaleX: 1, scaleY: 1},
+			rect:      image.Rect(10, 20, 30, 50),
+			wantRect:  image.Rect(15, 27, 35, 57),
+			point:     image.Pt(10, 20), wantPoint: image.Pt(15, 27),
+		},
+		{
+			// 101x61 resized to 50x30
+			name:      "resize to odd scale",
+			transform: Transform{sourceSize: source, crop: full, scaleX: 101.0 / 50, scaleY: 61.0 / 30},
+			rect:      image.Rect(10, 20, 30, 50),
+			wantRect:  image.Rect(20, 41, 61, 102),
+			point:     image.Pt(10, 20), wantPoint: image.Pt(20, 41),
+		},
+		{
+			name:      "rotate 270 and crop",
+			transform: Transform{sourceSize: source, rotate: 270, crop: image.Rect(2, 4, 50, 90), scaleX: 1, scaleY: 1},
+			rect:      image.Rect(10, 20, 30, 50),
+			wantRect:  image.Rect(47, 12, 77, 32),
+			point:     image.Pt(10, 20), wantPoint: image.Pt(77, 12),
+		},
+		{
+			// Mirrored, rotated to 61x101, cropped to 59x97 and resized to 29x48
+			name: "all steps",
+			transform: Transform{
+				sourceSize: source, mirror: true, rotate: 90,
+				crop:   image.Rect(1, 3, 60, 100),
+				scaleX: 59.0 / 29, scaleY: 97.0 / 48,
+			},
+			rect:     image.Rect(10, 20, 20, 40),
+			wantRect: image.Rect(17, 19, 58, 40),
+			point:    image.Pt(10, 20), wantPoint: image.Pt(58, 40),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.transform.ToOriginal(tt.rect); got != tt.wantRect {
+				t.Errorf("ToOriginal(%v) = %v, want %v", tt.rect, got, tt.wantRect)
+			}
+			if got := tt.transform.PointToOriginal(tt.point); got != tt.wantPoint {
+				t.Errorf("PointToOriginal(%v) = %v, want %v", tt.point, got, tt.wantPoint)
+			}
+			if got := tt.transform.ToOriginal(image.Rectangle{}); !got.Empty() {
+				t.Errorf("ToOriginal of an empty rectangle = %v, want empty", got)
+			}
+		})
+	}
+}
+
+func TestTransformVectorToOriginal(t *testing.T) {
+	tests := []struct {
+		name         string
+		transform    Transform
+		dx, dy       float64
+		wantX, wantY float64
+	}{
+		{"zero value", Transform{}, 3, -2, 3, -2},
+		{"mirror", Transform{sourceSize: source, mirror: true, scaleX: 1, scaleY: 1}, 3, -2, -3, -2},
+		{"rotate 90", Transform{sourceSize: source, rotate: 90, scaleX: 1, scaleY: 1}, 1, 0, 0, -1},
+		{"rotate 180", Transform{sourceSize: source, rotate: 180, scaleX: 1, scaleY: 1}, 1, 2, -1, -2},
+		{"rotate 270", Transform{sourceSize: source, rotate: 270, scaleX: 1, scaleY: 1}, 1, 0, 0, 1},
+		{"crop doesn't move vectors", Transform{sourceSize: source, crop: image.Rect(5, 7, 85, 57), scaleX: 1, scaleY: 1}, 3, -2, 3, -2},
+		{"resize", Transform{sourceSize: source, scaleX: 101.0 / 50, scaleY: 61.0 / 30}, 10, 3, 20.2, 6.1},
+		{"mirror and rotate 90", Transform{sourceSize: source, mirror: true, rotate: 90, scaleX: 1, scaleY: 1}, 0, 1, -1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, y := tt.transform.VectorToOriginal(tt.dx, tt.dy)
+			if math.Abs(x-tt.wantX) > 1e-9 || math.Abs(y-tt.wantY) > 1e-9 {
+				t.Errorf("VectorToOriginal(%v, %v) = (%v, %v), want (%v, %v)", tt.dx, tt.dy, x, y, tt.wantX, tt.wantY)
+			}
+		})
+	}
+}
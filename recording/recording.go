@@ -2,6 +2,7 @@ package recording
 
 import (
 	"fmt"
+	"image"
 	"time"
 
 	"gocv.io/x/gocv"
@@ -9,8 +10,8 @@ import (
 	"tracker/types"
 )
 
-// StartRecording starts video recording with the given configuration
-func StartRecording(state *types.AppState, frame gocv.Mat, config types.VideoConfig) error {
+// StartRecording starts video recording of frames of the given size
+func StartRecording(state *types.AppState, size image.Point, config types.VideoConfig) error {
 	if state.IsRecording {
 		return fmt.Errorf("recording already active")
 	}
@@ -24,7 +25,7 @@ func StartRecording(state *types.AppState, frame gocv.Mat, config types.VideoCon
 	var usedCodec string
 
 	for _, fourcc := range config.Codecs {
-		vw, err = gocv.VideoWriterFile(filename, fourcc, config.FPS, frame.Cols(), frame.Rows(), true)
+		vw, err = gocv.VideoWriterFile(filename, fourcc, config.FPS, size.X, size.Y, true)
 		if err == nil {
 			usedCodec = fourcc
 			break
@@ -63,11 +64,11 @@ func StopRecording(state *types.AppState) error {
 }
 
 // ToggleRecording toggles video recording on/off
-func ToggleRecording(state *types.AppState, frame gocv.Mat, config types.VideoConfig) error {
+func ToggleRecording(state *types.AppState, size image.Point, config types.VideoConfig) error {
 	if state.IsRecording {
 		return StopRecording(state)
 	}
-	return StartRecording(state, frame, config)
+	return StartRecording(state, size, config)
 }
 
 // WriteFrame writes a frame to the video file if recording is active
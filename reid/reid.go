@@ -0,0 +1,141 @@
+package reid
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// Histogram bins and ranges over hue and saturation. Value is left out so the
+// model tolerates lighting changes.
+var (
+	histChannels = []int{0, 1}
+	histSize     = []int{30, 32}
+	histRanges   = []float64{0, 180, 0, 256}
+)
+
+// templateSize is the size regions are scaled to before their structure is compared
+var templateSize = image.Pt(32, 32)
+
+// Model is the appearance of a target, captured as a normalized hue/saturation
+// histogram for color and a small grayscale template for structure
+type Model struct {
+	hist     gocv.Mat
+	template gocv.Mat
+}
+
+// NewModel captures the appearance of the region roi of frame
+func NewModel(frame gocv.Mat, roi image.Rectangle) (*Model, error) {
+	hist, err := histogram(frame, roi)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := grayTemplate(frame, roi)
+	if err != nil {
+		_ = hist.Close()
+		return nil, err
+	}
+	return &Model{hist: hist, template: template}, nil
+}
+
+// Similarity compares the region roi of frame with the model and returns a
+// score from 0 (nothing in common) to 1 (identical color distribution)
+func (m *Model) Similarity(frame gocv.Mat, roi image.Rectangle) (float64, error) {
+	hist, err := histogram(frame, roi)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = hist.Close() }()
+
+	distance := gocv.CompareHist(m.hist, hist, gocv.HistCmpBhattacharya)
+	return 1 - float64(distance), nil
+}
+
+// TemplateSimilarity compares the structure of the region roi of frame with the
+// template captured at initialization. It returns the normalized correlation
+// clamped to 0 (unrelated) .. 1 (identical).
+func (m *Model) TemplateSimilarity(frame gocv.Mat, roi image.Rectangle) (float64, error) {
+	candidate, err := grayTemplate(frame, roi)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = candidate.Close() }()
+
+	result := gocv.NewMat()
+	defer func() { _ = result.Close() }()
+
+	mask := gocv.NewMat()
+	defer func() { _ = mask.Close() }()
+
+	// Equal sizes produce a single correlation value
+	if err := gocv.MatchTemplate(candidate, m.template, &result, gocv.TmCcoeffNormed, mask); err != nil {
+		return 0, fmt.Errorf("error matching template: %v", err)
+	}
+	_, score, _, _ := gocv.MinMaxLoc(result)
+
+	return math.Max(0, math.Min(1, float64(score))), nil
+}
+
+// Close releases the histogram and template
+func (m *Model) Close() error {
+	_ = m.template.Close()
+	return m.hist.Close()
+}
+
+// grayTemplate converts a frame region to a grayscale patch of templateSize
+func grayTemplate(frame gocv.Mat, roi image.Rectangle) (gocv.Mat, error) {
+	roi = roi.Intersect(image.Rect(0, 0, frame.Cols(), frame.Rows()))
+	if roi.Empty() {
+		return gocv.Mat{}, fmt.Errorf("template region is outside the frame")
+	}
+
+	region := frame.Region(roi)
+	defer func() { _ = region.Close() }()
+
+	gray := gocv.NewMat()
+	defer func() { _ = gray.Close() }()
+	if err := gocv.CvtColor(region, &gray, gocv.ColorBGRToGray); err != nil {
+		return gocv.Mat{}, fmt.Errorf("error converting to grayscale: %v", err)
+	}
+
+	template := gocv.NewMat()
+	if err := gocv.Resize(gray, &template, templateSize, 0, 0, gocv.InterpolationArea); err != nil {
+		_ = template.Close()
+		return gocv.Mat{}, fmt.Errorf("error resizing template: %v", err)
+	}
+	return template, nil
+}
+
+// histogram computes the normalized hue/saturation histogram of a frame region
+func histogram(frame gocv.Mat, roi image.Rectangle) (gocv.Mat, error) {
+	roi = roi.Intersect(image.Rect(0, 0, frame.Cols(), frame.Rows()))
+	if roi.Empty() {
+		return gocv.Mat{}, fmt.Errorf("appearance region is outside the frame")
+	}
+
+	region := frame.Region(roi)
+	defer func() { _ = region.Close() }()
+
+	hsv := gocv.NewMat()
+	defer func() { _ = hsv.Close() }()
+	if err := gocv.CvtColor(region, &hsv, gocv.ColorBGRToHSV); err != nil {
+		return gocv.Mat{}, fmt.Errorf("error converting to HSV: %v", err)
+	}
+
+	mask := gocv.NewMat()
+	defer func() { _ = mask.Close() }()
+
+	hist := gocv.NewMat()
+	if err := gocv.CalcHist([]gocv.Mat{hsv}, histChannels, mask, &hist, histSize, histRanges, false); err != nil {
+		_ = hist.Close()
+		return gocv.Mat{}, fmt.Errorf("error computing histogram: %v", err)
+	}
+	if err := gocv.Normalize(hist, &hist, 0, 1, gocv.NormMinMax); err != nil {
+		_ = hist.Close()
+		return gocv.Mat{}, fmt.Errorf("error normalizing histogram: %v", err)
+	}
+	return hist, nil
+}
@@ -0,0 +1,241 @@
+package source
+
+import (
+	"errors"
+	"image"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// reconnectPollInterval is how often the capture goroutine polls a source that is reconnecting
+const reconnectPollInterval = 15 * time.Millisecond
+
+// closeGracePeriod is how long Close waits for a pending read to finish.
+// After that Close returns and the capture goroutine closes the wrapped
+// source in the background once the read returns.
+const closeGracePeriod = 250 * time.Millisecond
+
+// capturedFrame is a frame handed from the capture goroutine to the consumer
+type capturedFrame struct {
+	mat       gocv.Mat
+	timestamp time.Duration
+	index     int
+	name      string
+	err       error
+}
+
+// release frees the frame's Mat, if it carries one
+func (f capturedFrame) release() {
+	if f.err == nil {
+		_ = f.mat.Close()
+	}
+}
+
+// AsyncSource captures frames from another source on its own goroutine. Frames
+// are queued in a bounded buffer; when the consumer falls behind, the oldest
+// queued frame is dropped so Read always returns the freshest frames.
+type AsyncSource struct {
+	src    FrameSource
+	frames chan capturedFrame
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	dropped  atomic.Uint64
+	captured int
+
+	// err is the terminal error of the capture goroutine, valid once frames is closed
+	err error
+	// closeErr is the error of closing the wrapped source, valid once the capture goroutine exits
+	closeErr error
+
+	// Metadata of the last frame returned by Read
+	timestamp time.Duration
+	index     int
+	name      string
+
+	closeOnce sync.Once
+}
+
+// NewAsyncSource starts capturing from src with room for bufferSize queued frames
+func NewAsyncSource(src FrameSource, bufferSize int) *AsyncSource {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+
+	s := &AsyncSource{
+		src:    src,
+		frames: make(chan capturedFrame, bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.captureLoop()
+	return s
+}
+
+// captureLoop reads frames from the wrapped source until it fails or the source
+// is closed. It owns the wrapped source and closes it itself once Close has
+// been called, so the source is never closed while a read is in flight.
+func (s *AsyncSource) captureLoop() {
+	defer s.wg.Done()
+	defer func() {
+		<-s.done
+		s.closeErr = s.src.Close()
+	}()
+	defer close(s.frames)
+
+	labeler, _ := s.src.(FrameLabeler)
+
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		mat := gocv.NewMat()
+		err := s.src.Read(&mat)
+
+		// Close may have been called while the read was pending
+		select {
+		case <-s.done:
+			_ = mat.Close()
+			return
+		default:
+		}
+
+		if errors.Is(err, ErrReconnecting) {
+			_ = mat.Close()
+			s.push(capturedFrame{err: err})
+			time.Sleep(reconnectPollInterval)
+			continue
+		}
+		if err != nil {
+			_ = mat.Close()
+			s.err = err
+			return
+		}
+
+		frame := capturedFrame{
+			mat:       mat,
+			timestamp: s.src.Timestamp(),
+			index:     s.captured,
+		}
+		if labeler != nil {
+			frame.index = labeler.FrameIndex()
+			frame.name = labeler.FrameName()
+		}
+		s.captured++
+
+		s.push(frame)
+	}
+}
+
+// push queues a frame, dropping the oldest queued frame while the buffer is full
+func (s *AsyncSource) push(frame capturedFrame) {
+	for {
+		select {
+		case <-s.done:
+			frame.release()
+			return
+		case s.frames <- frame:
+			return
+		default:
+		}
+
+		select {
+		case old := <-s.frames:
+			if old.err == nil {
+				s.dropped.Add(1)
+			}
+			old.release()
+		default:
+		}
+	}
+}
+
+// Read waits for the next queued frame and moves it into dst
+func (s *AsyncSource) Read(dst *gocv.Mat) error {
+	frame, ok := <-s.frames
+	if !ok {
+		if s.err != nil {
+			return s.err
+		}
+		return ErrEndOfStream
+	}
+	if frame.err != nil {
+		return frame.err
+	}
+
+	// Hand over the captured Mat instead of copying the pixels
+	_ = dst.Close()
+	*dst = frame.mat
+
+	s.timestamp = frame.timestamp
+	s.index = frame.index
+	s.name = frame.name
+	return nil
+}
+
+// Dropped returns the number of frames discarded because the consumer fell behind
+func (s *AsyncSource) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Size returns the frame size of the wrapped source
+func (s *AsyncSource) Size() image.Point {
+	return s.src.Size()
+}
+
+// FPS returns the frame rate of the wrapped source
+func (s *AsyncSource) FPS() float64 {
+	return s.src.FPS()
+}
+
+// Timestamp returns the capture time of the last frame returned by Read
+func (s *AsyncSource) Timestamp() time.Duration {
+	return s.timestamp
+}
+
+// FrameIndex returns the capture sequence number of the last frame, so dropped frames show up as gaps
+func (s *AsyncSource) FrameIndex() int {
+	return s.index
+}
+
+// FrameName returns the name of the last frame if the wrapped source provides one
+func (s *AsyncSource) FrameName() string {
+	return s.name
+}
+
+// Close stops the capture goroutine, discards queued frames and closes the
+// wrapped source. When a read blocks on a stalled stream for longer than
+// closeGracePeriod, Close returns without waiting for it and the source is
+// closed in the background once the read returns.
+func (s *AsyncSource) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		// Release anything still queued until the capture goroutine exits
+		stopped := make(chan struct{})
+		go func() {
+			for frame := range s.frames {
+				frame.release()
+			}
+			s.wg.Wait()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			err = s.closeErr
+		case <-time.After(closeGracePeriod):
+			log.Printf("Source still blocked in a read after %s, closing it in the background", closeGracePeriod)
+		}
+	})
+	return err
+}
@@ -0,0 +1,102 @@
+package source
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// defaultFileFPS is used when a video file doesn't report its frame rate
+const defaultFileFPS = 30.0
+
+// VideoFileSource reads frames from a recorded video file
+type VideoFileSource struct {
+	path      string
+	vc        *gocv.VideoCapture
+	loop      bool
+	fps       float64
+	timestamp time.Duration
+
+	// loopOffset keeps timestamps increasing after the file is rewound
+	loopOffset time.Duration
+}
+
+// OpenVideoFile opens a video file, optionally rewinding it when it ends
+func OpenVideoFile(path string, loop bool) (*VideoFileSource, error) {
+	vc, err := gocv.VideoCaptureFile(path)
+	if err != nil {
+		_ = vc.Close()
+		return nil, fmt.Errorf("failed to open video file %s: %v", path, err)
+	}
+
+	fps := vc.Get(gocv.VideoCaptureFPS)
+	if fps <= 0 {
+		fps = defaultFileFPS
+	}
+
+	return &VideoFileSource{
+		path: path,
+		vc:   vc,
+		loop: loop,
+		fps:  fps,
+	}, nil
+}
+
+// Read reads the next frame, rewinding the file at the end when looping is enabled
+func (s *VideoFileSource) Read(dst *gocv.Mat) error {
+	if s.readFrame(dst) {
+		return nil
+	}
+
+	if !s.loop {
+		return ErrEndOfStream
+	}
+
+	// Rewind and continue timestamps from one frame after the last one
+	s.loopOffset = s.timestamp + s.frameInterval()
+	s.vc.Set(gocv.VideoCapturePosFrames, 0)
+	if s.readFrame(dst) {
+		return nil
+	}
+
+	// A file that can't produce a frame right after rewinding is empty or broken
+	return ErrEndOfStream
+}
+
+// readFrame reads one frame and records its position in the file
+func (s *VideoFileSource) readFrame(dst *gocv.Mat) bool {
+	if ok := s.vc.Read(dst); !ok || dst.Empty() {
+		return false
+	}
+
+	position := time.Duration(s.vc.Get(gocv.VideoCapturePosMsec) * float64(time.Millisecond))
+	s.timestamp = s.loopOffset + position
+	return true
+}
+
+// frameInterval returns the duration of a single frame
+func (s *VideoFileSource) frameInterval() time.Duration {
+	return time.Duration(float64(time.Second) / s.fps)
+}
+
+// Size returns the frame size of the video
+func (s *VideoFileSource) Size() image.Point {
+	return captureSize(s.vc)
+}
+
+// FPS returns the frame rate of the video
+func (s *VideoFileSource) FPS() float64 {
+	return s.fps
+}
+
+// Timestamp returns the position of the last frame read, including completed loops
+func (s *VideoFileSource) Timestamp() time.Duration {
+	return s.timestamp
+}
+
+// Close releases the video file
+func (s *VideoFileSource) Close() error {
+	return s.vc.Close()
+}
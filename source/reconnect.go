@@ -0,0 +1,209 @@
+package source
+
+import (
+	"errors"
+	"image"
+	"log"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// ErrReconnecting is returned by Read while the connection to a source is being re-established
+var ErrReconnecting = errors.New("reconnecting")
+
+// minReconnectDelay keeps the reconnect backoff from spinning when a zero or
+// negative delay is configured
+const minReconnectDelay = 10 * time.Millisecond
+
+// Opener opens a new connection to a frame source
+type Opener func() (FrameSource, error)
+
+// ReconnectingSource supervises a frame source and reopens it with exponential
+// backoff whenever reading fails. Frames keep a continuous timeline across reconnects.
+type ReconnectingSource struct {
+	open         Opener
+	initialDelay time.Duration
+	maxDelay     time.Duration
+
+	mu           sync.Mutex
+	current      FrameSource
+	reading      bool
+	reconnecting bool
+	closed       bool
+	done         chan struct{}
+	size         image.Point
+	fps          float64
+	attempts     int
+
+	startTime time.Time
+	timestamp time.Duration
+}
+
+// NewReconnectingSource opens a source and keeps it connected. The first
+// connection attempt is made synchronously and its error is returned. The
+// delays are raised to at least minReconnectDelay, and maxDelay to at least
+// initialDelay.
+func NewReconnectingSource(open Opener, initialDelay, maxDelay time.Duration) (*ReconnectingSource, error) {
+	initialDelay = max(initialDelay, minReconnectDelay)
+	maxDelay = max(maxDelay, initialDelay)
+
+	src, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReconnectingSource{
+		open:         open,
+		initialDelay: initialDelay,
+		maxDelay:     maxDelay,
+		current:      src,
+		done:         make(chan struct{}),
+		size:         src.Size(),
+		fps:          src.FPS(),
+		startTime:    time.Now(),
+	}, nil
+}
+
+// Read reads the next frame, returning ErrReconnecting while the source is unavailable
+func (s *ReconnectingSource) Read(dst *gocv.Mat) error {
+	s.mu.Lock()
+	current := s.current
+	if s.reconnecting || current == nil {
+		s.mu.Unlock()
+		return ErrReconnecting
+	}
+	s.reading = true
+	s.mu.Unlock()
+
+	err := current.Read(dst)
+	s.endRead(current)
+	if err != nil {
+		log.Printf("Source lost: %v. Reconnecting...", err)
+		s.startReconnect(current)
+		return ErrReconnecting
+	}
+
+	s.timestamp = time.Since(s.startTime)
+	return nil
+}
+
+// endRead marks the read from current as finished, and closes current if
+// Close was called while the read was in flight
+func (s *ReconnectingSource) endRead(current FrameSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reading = false
+	if s.closed && s.current == current {
+		_ = current.Close()
+		s.current = nil
+	}
+}
+
+// startReconnect drops the failed connection and starts the reconnect loop
+func (s *ReconnectingSource) startReconnect(failed FrameSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.reconnecting {
+		return
+	}
+
+	_ = failed.Close()
+	s.current = nil
+	s.reconnecting = true
+	s.attempts = 0
+	go s.reconnectLoop()
+}
+
+// reconnectLoop retries opening the source with exponential backoff until it succeeds or the source is closed
+func (s *ReconnectingSource) reconnectLoop() {
+	delay := s.initialDelay
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-time.After(delay):
+		}
+
+		s.mu.Lock()
+		s.attempts++
+		attempt := s.attempts
+		s.mu.Unlock()
+
+		src, err := s.open()
+		if err != nil {
+			delay *= 2
+			if delay > s.maxDelay {
+				delay = s.maxDelay
+			}
+			log.Printf("Reconnect attempt %d failed: %v (next in %s)", attempt, err, delay)
+			continue
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = src.Close()
+			return
+		}
+		s.current = src
+		s.reconnecting = false
+		s.size = src.Size()
+		if fps := src.FPS(); fps > 0 {
+			s.fps = fps
+		}
+		s.mu.Unlock()
+
+		log.Printf("Source reconnected after %d attempt(s)", attempt)
+		return
+	}
+}
+
+// Reconnecting reports whether the source is currently trying to reconnect
+func (s *ReconnectingSource) Reconnecting() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reconnecting
+}
+
+// Size returns the frame size of the most recent connection
+func (s *ReconnectingSource) Size() image.Point {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// FPS returns the frame rate of the most recent connection
+func (s *ReconnectingSource) FPS() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fps
+}
+
+// Timestamp returns the time of the last frame since the source was first opened
+func (s *ReconnectingSource) Timestamp() time.Duration {
+	return s.timestamp
+}
+
+// Close stops reconnecting and closes the current connection. A connection
+// that is being read from is left to Read to close once the read returns.
+func (s *ReconnectingSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+
+	if s.current != nil && !s.reading {
+		err := s.current.Close()
+		s.current = nil
+		return err
+	}
+	return nil
+}
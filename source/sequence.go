@@ -0,0 +1,276 @@
+package source
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// imageExtensions lists the file types picked up from a sequence directory
+var imageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".bmp":  true,
+}
+
+// FrameLabeler is implemented by sources whose frames carry their own identity,
+// so results can be matched back to the original frame
+type FrameLabeler interface {
+	// FrameIndex returns the frame number of the last frame read
+	FrameIndex() int
+	// FrameName returns the name of the last frame read
+	FrameName() string
+}
+
+// sequenceFrame is a single image of a sequence
+type sequenceFrame struct {
+	index int
+	path  string
+}
+
+// ImageSequenceSource reads a directory of numbered images as a frame stream
+type ImageSequenceSource struct {
+	frames    []sequenceFrame
+	position  int
+	current   sequenceFrame
+	loop      bool
+	fps       float64
+	size      image.Point
+	timestamp time.Duration
+
+	// loopOffset keeps timestamps increasing after the sequence restarts
+	loopOffset time.Duration
+}
+
+// OpenImageSequence opens a directory of images ordered by the frame number in
+// their filenames. Only frames with startIndex <= number <= endIndex are read;
+// a negative endIndex means no upper limit.
+func OpenImageSequence(dir string, fps float64, startIndex, endIndex int, loop bool) (*ImageSequenceSource, error) {
+	frames, err := listSequenceFrames(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep only the requested range
+	selected := frames[:0]
+	for _, f := range frames {
+		if f.index < startIndex || (endIndex >= 0 && f.index > endIndex) {
+			continue
+		}
+		selected = append(selected, f)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no images in %s within frame range %d..%d", dir, startIndex, endIndex)
+	}
+
+	if fps <= 0 {
+		fps = defaultFileFPS
+	}
+
+	// Use the first image to determine the frame size
+	first := gocv.IMRead(selected[0].path, gocv.IMReadColor)
+	defer func() { _ = first.Close() }()
+	if first.Empty() {
+		return nil, fmt.Errorf("failed to read image %s", selected[0].path)
+	}
+
+	return &ImageSequenceSource{
+		frames: selected,
+		loop:   loop,
+		fps:    fps,
+		size:   image.Pt(first.Cols(), first.Rows()),
+	}, nil
+}
+
+// listSequenceFrames returns the images in a directory sorted by frame number
+func listSequenceFrames(dir string) ([]sequenceFrame, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image directory %s: %v", dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || !imageExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+
+	frames := make([]sequenceFrame, len(names))
+	for i, number := range frameNumbers(names) {
+		frames[i] = sequenceFrame{index: number, path: filepath.Join(dir, names[i])}
+	}
+
+	// Files without a number in their name go last and are numbered on from
+	// the largest frame number, so their indices can't collide with a real one
+	sort.SliceStable(frames, func(i, j int) bool {
+		numbered, otherNumbered := frames[i].index >= 0, frames[j].index >= 0
+		if numbered != otherNumbered {
+			return numbered
+		}
+		if frames[i].index != frames[j].index {
+			return frames[i].index < frames[j].index
+		}
+		return frames[i].path < frames[j].path
+	})
+
+	next := 0
+	for i := range frames {
+		if frames[i].index < 0 {
+			frames[i].index = next
+		}
+		next = frames[i].index + 1
+	}
+
+	return frames, nil
+}
+
+// frameNumbers returns the frame number of each file name, or -1 for names
+// without digits. Names that differ only in their digits belong to one
+// sequence, and their frame number is the group of digits that changes from
+// file to file, the last one if several do, so that frame_0001_v2.png is
+// frame 1 and cam3_0002.png frame 2. When no group changes, e.g. for a single
+// file, the longest group of digits is used.
+func frameNumbers(names []string) []int {
+	runs := make([][]string, len(names))
+	sequences := make(map[string][]int)
+	for i, name := range names {
+		var pattern string
+		pattern, runs[i] = splitDigits(strings.TrimSuffix(name, filepath.Ext(name)))
+		sequences[pattern] = append(sequences[pattern], i)
+	}
+
+	numbers := make([]int, len(names))
+	for _, files := range sequences {
+		varying := varyingRun(runs, files)
+		for _, i := range files {
+			run := varying
+			if run < 0 {
+				run = longestRun(runs[i])
+			}
+			numbers[i] = -1
+			if run >= 0 {
+				if n, err := strconv.Atoi(runs[i][run]); err == nil {
+					numbers[i] = n
+				}
+			}
+		}
+	}
+	return numbers
+}
+
+// splitDigits returns the name with every group of digits replaced by #, and
+// the groups of digits in order
+func splitDigits(name string) (string, []string) {
+	var pattern strings.Builder
+	var runs []string
+	for i := 0; i < len(name); {
+		if name[i] < '0' || name[i] > '9' {
+			pattern.WriteByte(name[i])
+			i++
+			continue
+		}
+		start := i
+		for i < len(name) && name[i] >= '0' && name[i] <= '9' {
+			i++
+		}
+		pattern.WriteByte('#')
+		runs = append(runs, name[start:i])
+	}
+	return pattern.String(), runs
+}
+
+// varyingRun returns the position of the last group of digits whose value
+// differs between the files of a sequence, or -1 if none does
+func varyingRun(runs [][]string, files []int) int {
+	first := runs[files[0]]
+	for run := len(first) - 1; run >= 0; run-- {
+		value, _ := strconv.Atoi(first[run])
+		for _, i := range files[1:] {
+			if other, _ := strconv.Atoi(runs[i][run]); other != value {
+				return run
+			}
+		}
+	}
+	return -1
+}
+
+// longestRun returns the position of the longest group of digits, the last
+// one of several as long, or -1 if there is none
+func longestRun(runs []string) int {
+	longest := -1
+	for i, run := range runs {
+		if longest < 0 || len(run) >= len(runs[longest]) {
+			longest = i
+		}
+	}
+	return longest
+}
+
+// Read reads the next image of the sequence
+func (s *ImageSequenceSource) Read(dst *gocv.Mat) error {
+	if s.position >= len(s.frames) {
+		if !s.loop {
+			return ErrEndOfStream
+		}
+		s.loopOffset = s.timestamp + time.Duration(float64(time.Second)/s.fps)
+		s.position = 0
+	}
+
+	frame := s.frames[s.position]
+	s.position++
+
+	img := gocv.IMRead(frame.path, gocv.IMReadColor)
+	defer func() { _ = img.Close() }()
+	if img.Empty() {
+		return fmt.Errorf("failed to read image %s", frame.path)
+	}
+	if err := img.CopyTo(dst); err != nil {
+		return fmt.Errorf("failed to copy image %s: %v", frame.path, err)
+	}
+
+	// Derive the timestamp from the frame number so gaps in the numbering are preserved
+	elapsed := float64(frame.index-s.frames[0].index) / s.fps
+	s.timestamp = s.loopOffset + time.Duration(elapsed*float64(time.Second))
+	s.current = frame
+	return nil
+}
+
+// Size returns the size of the first image of the sequence
+func (s *ImageSequenceSource) Size() image.Point {
+	return s.size
+}
+
+// FPS returns the configured playback rate
+func (s *ImageSequenceSource) FPS() float64 {
+	return s.fps
+}
+
+// Timestamp returns the time of the last frame relative to the first frame of the sequence
+func (s *ImageSequenceSource) Timestamp() time.Duration {
+	return s.timestamp
+}
+
+// FrameIndex returns the frame number parsed from the last image's filename
+func (s *ImageSequenceSource) FrameIndex() int {
+	return s.current.index
+}
+
+// FrameName returns the filename of the last image read
+func (s *ImageSequenceSource) FrameName() string {
+	return filepath.Base(s.current.path)
+}
+
+// Close is a no-op, images are loaded one at a time
+func (s *ImageSequenceSource) Close() error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestListSequenceFramesNumbersUnnumberedAfterLast(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"frame_0002.png", "cover.png", "frame_0000.png", "frame_0001.png", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	frames, err := listSequenceFrames(dir)
+	if err != nil {
+		t.Fatalf("listSequenceFrames() error = %v", err)
+	}
+
+	want := []struct {
+		name  string
+		index int
+	}{
+		{"frame_0000.png", 0},
+		{"frame_0001.png", 1},
+		{"frame_0002.png", 2},
+		{"cover.png", 3},
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(frames), len(want))
+	}
+	for i, w := range want {
+		if filepath.Base(frames[i].path) != w.name || frames[i].index != w.index {
+			t.Errorf("frame %d = %s #%d, want %s #%d", i, filepath.Base(frames[i].path), frames[i].index, w.name, w.index)
+		}
+	}
+}
+
+func TestFrameNumbers(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []int
+	}{
+		{"plain", []string{"0001.png", "0002.png", "0010.png"}, []int{1, 2, 10}},
+		{"trailing version", []string{"frame_0001_v2.png", "frame_0002_v2.png", "frame_0003_v2.png"}, []int{1, 2, 3}},
+		{"single file with a version", []string{"frame_0007_v2.png"}, []int{7}},
+		{"camera number first", []string{"cam3_0001.png", "cam3_0002.png"}, []int{1, 2}},
+		{"date and frame", []string{"20240101_001.jpg", "20240101_002.jpg"}, []int{1, 2}},
+		{"unpadded", []string{"img9.png", "img10.png"}, []int{9, 10}},
+		{"no number", []string{"cover.png", "frame_0004.png"}, []int{-1, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := frameNumbers(tt.files); !slices.Equal(got, tt.want) {
+				t.Errorf("frameNumbers(%v) = %v, want %v", tt.files, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package source
+
+import (
+	"errors"
+	"image"
+	"os"
+	"strconv"
+	"time"
+
+	"gocv.io/x/gocv"
+
+	"tracker/types"
+)
+
+// ErrEndOfStream is returned by Read when a finite source has no more frames
+var ErrEndOfStream = errors.New("end of stream")
+
+// FrameSource is a stream of frames that feeds the tracking pipeline
+type FrameSource interface {
+	// Read reads the next frame into dst
+	Read(dst *gocv.Mat) error
+	// Size returns the frame size reported by the source
+	Size() image.Point
+	// FPS returns the nominal frame rate of the source
+	FPS() float64
+	// Timestamp returns the presentation time of the last frame read
+	Timestamp() time.Duration
+	// Close releases the underlying capture resources
+	Close() error
+}
+
+// Open opens the frame source described by the configuration.
+// A numeric source is treated as a camera device index, a URL as a network
+// stream, a directory as an image sequence and anything else as a video file path.
+//
+// Live sources (cameras and streams) are captured on a separate goroutine when
+// config.CaptureBuffer is positive. Files are always read synchronously, since
+// dropping frames would defeat offline analysis.
+func Open(config types.SourceConfig) (FrameSource, error) {
+	if id, err := strconv.Atoi(config.Source); err == nil {
+		webcam, err := OpenWebcam(id)
+		if err != nil {
+			return nil, err
+		}
+		return withCaptureBuffer(webcam, config.CaptureBuffer), nil
+	}
+
+	if IsStreamURL(config.Source) {
+		opener := func() (FrameSource, error) { return OpenStream(config.Source) }
+		stream, err := NewReconnectingSource(opener, config.ReconnectDelay, config.ReconnectMaxDelay)
+		if err != nil {
+			return nil, err
+		}
+		return withCaptureBuffer(stream, config.CaptureBuffer), nil
+	}
+
+	info, err := os.Stat(config.Source)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return OpenImageSequence(config.Source, config.FPS, config.StartIndex, config.EndIndex, config.Loop)
+	}
+	return OpenVideoFile(config.Source, config.Loop)
+}
+
+// withCaptureBuffer moves capture of a live source onto its own goroutine if buffering is enabled
+func withCaptureBuffer(src FrameSource, bufferSize int) FrameSource {
+	if bufferSize <= 0 {
+		return src
+	}
+	return NewAsyncSource(src, bufferSize)
+}
+
+// captureSize returns the frame size reported by a video capture
+func captureSize(vc *gocv.VideoCapture) image.Point {
+	return image.Pt(int(vc.Get(gocv.VideoCaptureFrameWidth)), int(vc.Get(gocv.VideoCaptureFrameHeight)))
+}
@@ -0,0 +1,78 @@
+package source
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// streamSchemes lists the URL schemes opened as network streams
+var streamSchemes = []string{"rtsp://", "rtsps://", "http://", "https://"}
+
+// IsStreamURL reports whether a source spec refers to a network stream
+func IsStreamURL(spec string) bool {
+	lower := strings.ToLower(spec)
+	for _, scheme := range streamSchemes {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamSource reads frames from a network stream such as RTSP or HTTP MJPEG
+type StreamSource struct {
+	url       string
+	vc        *gocv.VideoCapture
+	startTime time.Time
+	timestamp time.Duration
+}
+
+// OpenStream connects to a network stream
+func OpenStream(url string) (*StreamSource, error) {
+	vc, err := gocv.VideoCaptureFile(url)
+	if err != nil {
+		_ = vc.Close()
+		return nil, fmt.Errorf("failed to open stream %s: %v", url, err)
+	}
+
+	return &StreamSource{
+		url:       url,
+		vc:        vc,
+		startTime: time.Now(),
+	}, nil
+}
+
+// Read reads the next frame from the stream
+func (s *StreamSource) Read(dst *gocv.Mat) error {
+	if ok := s.vc.Read(dst); !ok || dst.Empty() {
+		return fmt.Errorf("failed to read from stream %s", s.url)
+	}
+
+	// Stream positions restart with every connection, so use wall clock time instead
+	s.timestamp = time.Since(s.startTime)
+	return nil
+}
+
+// Size returns the frame size of the stream
+func (s *StreamSource) Size() image.Point {
+	return captureSize(s.vc)
+}
+
+// FPS returns the frame rate advertised by the stream
+func (s *StreamSource) FPS() float64 {
+	return s.vc.Get(gocv.VideoCaptureFPS)
+}
+
+// Timestamp returns the receive time of the last frame relative to connecting
+func (s *StreamSource) Timestamp() time.Duration {
+	return s.timestamp
+}
+
+// Close disconnects from the stream
+func (s *StreamSource) Close() error {
+	return s.vc.Close()
+}
@@ -0,0 +1,248 @@
+package source
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// mjpegServer is a local stand-in for an IP camera. Every connection receives
+// framesPerConnection JPEG frames before the server drops it.
+func mjpegServer(t *testing.T, framesPerConnection int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 5), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encoding test frame: %v", err)
+	}
+	frame := buf.Bytes()
+
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connections.Add(1)
+		w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary=frame")
+		for i := 0; i < framesPerConnection; i++ {
+			_, _ = fmt.Fprintf(w, "--frame\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", len(frame))
+			_, _ = w.Write(frame)
+			_, _ = w.Write([]byte("\r\n"))
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &connections
+}
+
+func TestReconnectingSourceRecoversFromDroppedStream(t *testing.T) {
+	server, connections := mjpegServer(t, 20)
+	url := server.URL + "/video.mjpg"
+
+	src, err := NewReconnectingSource(func() (FrameSource, error) { return OpenStream(url) }, 10*time.Millisecond, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("opening stream: %v", err)
+	}
+	defer func() { _ = src.Close() }()
+
+	frame := gocv.NewMat()
+	defer func() { _ = frame.Close() }()
+
+	var framesBefore, framesAfter int
+	sawReconnect := false
+	var lastTimestamp time.Duration
+
+	deadline := time.Now().Add(20 * time.Second)
+	for time.Now().Before(deadline) && framesAfter < 5 {
+		err := src.Read(&frame)
+		switch {
+		case errors.Is(err, ErrReconnecting):
+			sawReconnect = true
+			time.Sleep(5 * time.Millisecond)
+		case err != nil:
+			t.Fatalf("unexpected read error: %v", err)
+		default:
+			if frame.Cols() != 64 || frame.Rows() != 48 {
+				t.Fatalf("frame size = %dx%d, want 64x48", frame.Cols(), frame.Rows())
+			}
+			if src.Timestamp() < lastTimestamp {
+				t.Fatalf("timestamp went backwards: %s < %s", src.Timestamp(), lastTimestamp)
+			}
+			lastTimestamp = src.Timestamp()
+			if sawReconnect {
+				framesAfter++
+			} else {
+				framesBefore++
+			}
+		}
+	}
+
+	if framesBefore == 0 {
+		t.Fatal("no frames read before the stream dropped")
+	}
+	if !sawReconnect {
+		t.Fatal("dropped stream was not reported as reconnecting")
+	}
+	if framesAfter < 5 {
+		t.Fatalf("read %d frames after reconnecting, want at least 5", framesAfter)
+	}
+	if connections.Load() < 2 {
+		t.Fatalf("server saw %d connections, want at least 2", connections.Load())
+	}
+}
+
+// failingSource is a frame source that always fails to read
+type failingSource struct{}
+
+func (failingSource) Read(*gocv.Mat) error     { return errors.New("read failed") }
+func (failingSource) Size() image.Point        { return image.Pt(640, 480) }
+func (failingSource) FPS() float64             { return 30 }
+func (failingSource) Timestamp() time.Duration { return 0 }
+func (failingSource) Close() error             { return nil }
+
+func TestReconnectingSourceBacksOff(t *testing.T) {
+	var opens atomic.Int32
+	opener := func() (FrameSource, error) {
+		if opens.Add(1) == 1 {
+			return failingSource{}, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	src, err := NewReconnectingSource(opener, 10*time.Millisecond, 40*time.Millisecond)
+	if err != nil {
+		t.Fatalf("opening source: %v", err)
+	}
+
+	frame := gocv.NewMat()
+	defer func() { _ = frame.Close() }()
+
+	if err := src.Read(&frame); !errors.Is(err, ErrReconnecting) {
+		t.Fatalf("Read() error = %v, want ErrReconnecting", err)
+	}
+	if !src.Reconnecting() {
+		t.Fatal("source should report reconnecting after a failed read")
+	}
+
+	// Delays of 10, 20, 40, 40... ms allow only a handful of attempts in 200ms
+	time.Sleep(200 * time.Millisecond)
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	attempts := opens.Load() - 1 // minus the initial connection
+	if attempts < 2 || attempts > 7 {
+		t.Fatalf("made %d reconnect attempts in 200ms, want between 2 and 7", attempts)
+	}
+
+	// Let an attempt that was already in flight finish before sampling
+	time.Sleep(10 * time.Millisecond)
+	closedAttempts := opens.Load()
+	time.Sleep(100 * time.Millisecond)
+	if opens.Load() != closedAttempts {
+		t.Fatal("source kept reconnecting after Close")
+	}
+}
+
+func TestReconnectingSourceClampsZeroDelay(t *testing.T) {
+	var opens atomic.Int32
+	opener := func() (FrameSource, error) {
+		if opens.Add(1) == 1 {
+			return failingSource{}, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	src, err := NewReconnectingSource(opener, 0, 0)
+	if err != nil {
+		t.Fatalf("opening source: %v", err)
+	}
+
+	frame := gocv.NewMat()
+	defer func() { _ = frame.Close() }()
+
+	if err := src.Read(&frame); !errors.Is(err, ErrReconnecting) {
+		t.Fatalf("Read() error = %v, want ErrReconnecting", err)
+	}
+
+	// A zero delay is raised to minReconnectDelay instead of retrying in a busy loop
+	time.Sleep(100 * time.Millisecond)
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	attempts := opens.Load() - 1 // minus the initial connection
+	if attempts < 1 || attempts > 12 {
+		t.Fatalf("made %d reconnect attempts in 100ms, want between 1 and 12", attempts)
+	}
+}
+
+// blockingSource is a frame source whose Read blocks until release is closed
+type blockingSource struct {
+	reading chan struct{}
+	release chan struct{}
+	closed  atomic.Bool
+}
+
+func (b *blockingSource) Read(*gocv.Mat) error {
+	close(b.reading)
+	<-b.release
+	if b.closed.Load() {
+		return errors.New("read from a closed source")
+	}
+	return nil
+}
+func (b *blockingSource) Size() image.Point        { return image.Pt(640, 480) }
+func (b *blockingSource) FPS() float64             { return 30 }
+func (b *blockingSource) Timestamp() time.Duration { return 0 }
+func (b *blockingSource) Close() error {
+	b.closed.Store(true)
+	return nil
+}
+
+func TestReconnectingSourceCloseWaitsForPendingRead(t *testing.T) {
+	blocking := &blockingSource{reading: make(chan struct{}), release: make(chan struct{})}
+	src, err := NewReconnectingSource(func() (FrameSource, error) { return blocking, nil }, 10*time.Millisecond, 40*time.Millisecond)
+	if err != nil {
+		t.Fatalf("opening source: %v", err)
+	}
+
+	frame := gocv.NewMat()
+	defer func() { _ = frame.Close() }()
+
+	readErr := make(chan error)
+	go func() { readErr <- src.Read(&frame) }()
+	<-blocking.reading
+
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if blocking.closed.Load() {
+		t.Fatal("connection closed while a read was in flight")
+	}
+
+	close(blocking.release)
+	if err := <-readErr; err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !blocking.closed.Load() {
+		t.Fatal("connection not closed after the pending read returned")
+	}
+}
@@ -0,0 +1,63 @@
+package source
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// WebcamSource reads frames from a local camera device
+type WebcamSource struct {
+	device    int
+	vc        *gocv.VideoCapture
+	startTime time.Time
+	timestamp time.Duration
+}
+
+// OpenWebcam opens the camera device with the given index
+func OpenWebcam(device int) (*WebcamSource, error) {
+	vc, err := gocv.VideoCaptureDevice(device)
+	if err != nil {
+		_ = vc.Close()
+		return nil, fmt.Errorf("failed to open video capture device %d: %v", device, err)
+	}
+
+	return &WebcamSource{
+		device:    device,
+		vc:        vc,
+		startTime: time.Now(),
+	}, nil
+}
+
+// Read reads the next frame from the camera
+func (s *WebcamSource) Read(dst *gocv.Mat) error {
+	if ok := s.vc.Read(dst); !ok {
+		return fmt.Errorf("failed to read from video capture device %d", s.device)
+	}
+
+	// Cameras don't report a usable position, so use the time since the device was opened
+	s.timestamp = time.Since(s.startTime)
+	return nil
+}
+
+// Size returns the capture resolution of the camera
+func (s *WebcamSource) Size() image.Point {
+	return captureSize(s.vc)
+}
+
+// FPS returns the frame rate reported by the camera driver
+func (s *WebcamSource) FPS() float64 {
+	return s.vc.Get(gocv.VideoCaptureFPS)
+}
+
+// Timestamp returns the capture time of the last frame relative to opening the device
+func (s *WebcamSource) Timestamp() time.Duration {
+	return s.timestamp
+}
+
+// Close releases the camera device
+func (s *WebcamSource) Close() error {
+	return s.vc.Close()
+}
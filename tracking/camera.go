@@ -0,0 +1,151 @@
+package tracking
+
+import (
+	"image"
+	"log"
+	"math"
+
+	"gocv.io/x/gocv"
+
+	"tracker/background"
+	"tracker/downscale"
+	"tracker/egomotion"
+	"tracker/motion"
+	"tracker/types"
+	"tracker/utils"
+)
+
+// CompensateCameraMotion estimates how the camera moved since the previous
+// frame and moves everything remembered in frame coordinates along with it,
+// so that only independent motion of objects is seen as motion
+func CompensateCameraMotion(state *types.AppState, frame gocv.Mat, config types.TrackingConfig) {
+	state.FrameMotion = motion.Identity()
+	if state.CameraMotion == nil {
+		return
+	}
+
+	m, ok := state.CameraMotion.Estimate(frame)
+	if !ok {
+		// Without a reliable estimate the camera is assumed to be still
+		return
+	}
+	state.FrameMotion = m
+	state.BackgroundAlignment = state.BackgroundAlignment.Compose(m.Invert())
+
+	warpTarget(state, frame, m, config)
+	for _, track := range state.Tracks {
+		track.Warp(m)
+	}
+	for _, track := range state.Candidates {
+		track.Warp(m)
+	}
+}
+
+// warpTarget moves the remembered position, motion model and trail of the
+// tracked target by the camera motion m
+func warpTarget(state *types.AppState, frame gocv.Mat, m motion.Affine, config types.TrackingConfig) {
+	center := utils.RectCenter(state.LastKnownRect)
+	x, y := m.ApplyF(float64(center.X), float64(center.Y))
+	state.TrackerShiftX += x - float64(center.X)
+	state.TrackerShiftY += y - float64(center.Y)
+
+	state.LastKnownRect = m.Rect(state.LastKnownRect)
+	state.PredictedRect = m.Rect(state.PredictedRect)
+	if state.TargetFilter != nil {
+		state.TargetFilter.Warp(m)
+	}
+	state.TargetHistory.Warp(m)
+	state.TargetSize = state.TargetSize.Scale(m.Scale())
+
+	// The tracker keeps its own search window and follows small moves by
+	// itself, so it is only restarted on the moved box once the camera has
+	// moved the target far since the tracker was last started. Restarting it
+	// on every frame of a steady pan would throw away its appearance model.
+	shift := math.Hypot(state.TrackerShiftX, state.TrackerShiftY)
+	if state.Mode.Current().HasTarget() && !state.LastKnownRect.Empty() && shift > config.CameraMotionReinitShift {
+		moveTracker(state, frame, state.LastKnownRect, config)
+	}
+}
+
+// moveTracker restarts the tracker on rect, keeping the current tracker if that fails
+func moveTracker(state *types.AppState, frame gocv.Mat, rect image.Rectangle, config types.TrackingConfig) {
+	rect = rect.Intersect(image.Rect(0, 0, frame.Cols(), frame.Rows()))
+	if rect.Empty() {
+		return
+	}
+
+	tracker, _, err := NewTracker(state.TrackerName, config.TrackingScale)
+	if err != nil {
+		log.Printf("Could not restart tracker after camera motion: %v", err)
+		return
+	}
+	if !tracker.Init(frame, rect) {
+		_ = tracker.Close()
+		return
+	}
+	_ = state.Tracker.Close()
+	state.Tracker = tracker
+	resetTrackerShift(state)
+}
+
+// resetTrackerShift starts adding up the camera motion of the target anew,
+// after its tracker has been (re)started
+func resetTrackerShift(state *types.AppState) {
+	state.TrackerShiftX = 0
+	state.TrackerShiftY = 0
+}
+
+// applyAligned applies background subtraction to the frame warped into the
+// coordinates the background model was learned in, and warps the foreground
+// mask back. Areas outside the current view are filled with the nearest edge
+// of the frame rather than black, so the model doesn't learn black as the
+// background there, and they are left out of the mask. When
+// the camera has moved so far that the model covers too little of the frame,
+// the model is restarted. The frame is at the detection scale.
+func applyAligned(state *types.AppState, frame gocv.Mat, config types.TrackingConfig) error {
+	size := image.Pt(frame.Cols(), frame.Rows())
+	alignment := downscale.Affine(state.BackgroundAlignment, config.DetectionScale)
+
+	// Mark the part of the frame that lies within the model
+	valid := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(255, 0, 0, 0), size.Y, size.X, gocv.MatTypeCV8U)
+	defer valid.Close()
+	alignedValid := gocv.NewMat()
+	defer alignedValid.Close()
+	if err := egomotion.Warp(valid, &alignedValid, alignment, size, gocv.BorderConstant); err != nil {
+		return err
+	}
+	if coverage := float64(gocv.CountNonZero(alignedValid)) / float64(size.X*size.Y); coverage < config.CameraMotionResetCoverage {
+		if err := restartBackground(state, config, coverage); err != nil {
+			return err
+		}
+		return state.BackSub.Apply(frame, &state.FgMask)
+	}
+
+	aligned := gocv.NewMat()
+	defer aligned.Close()
+	if err := egomotion.Warp(frame, &aligned, alignment, size, gocv.BorderReplicate); err != nil {
+		return err
+	}
+	alignedMask := gocv.NewMat()
+	defer alignedMask.Close()
+	if err := state.BackSub.Apply(aligned, &alignedMask); err != nil {
+		return err
+	}
+	if err := gocv.BitwiseAnd(alignedMask, alignedValid, &alignedMask); err != nil {
+		return err
+	}
+	return egomotion.Warp(alignedMask, &state.FgMask, alignment.Invert(), size, gocv.BorderConstant)
+}
+
+// restartBackground replaces the background model with a fresh one aligned with the current frame
+func restartBackground(state *types.AppState, config types.TrackingConfig, coverage float64) error {
+	backSub, err := background.New(config)
+	if err != nil {
+		return err
+	}
+	_ = state.BackSub.Close()
+	state.BackSub = backSub
+	state.BackgroundAlignment = motion.Identity()
+	log.Printf("Camera moved away from the background model (%.0f%% coverage), restarting it", coverage*100)
+	return nil
+}
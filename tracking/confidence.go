@@ -0,0 +1,70 @@
+package tracking
+
+import (
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+
+	"tracker/downscale"
+	"tracker/types"
+)
+
+// Weights of the confidence components. Components that can't be measured in
+// a frame are left out and the remaining weights are renormalized.
+const (
+	templateWeight   = 0.5
+	sizeWeight       = 0.3
+	foregroundWeight = 0.2
+)
+
+// ScoreConfidence rates how much a successful tracker update can be trusted,
+// from 0 (no confidence) to 1. It combines template similarity to the target
+// captured at initialization, size stability against the last known box and,
+// when background subtraction ran this frame, the share of the box covered by foreground.
+func ScoreConfidence(state *types.AppState, frame gocv.Mat, rect image.Rectangle, config types.TrackingConfig) float64 {
+	var total, weights float64
+
+	if state.TargetAppearance != nil {
+		if similarity, err := state.TargetAppearance.TemplateSimilarity(frame, rect); err == nil {
+			total += templateWeight * similarity
+			weights += templateWeight
+		}
+	}
+
+	if !state.LastKnownRect.Empty() {
+		lastArea := float64(state.LastKnownRect.Dx() * state.LastKnownRect.Dy())
+		area := float64(rect.Dx() * rect.Dy())
+		if lastArea > 0 && area > 0 {
+			total += sizeWeight * math.Min(area/lastArea, lastArea/area)
+			weights += sizeWeight
+		}
+	}
+
+	if state.ForegroundUpdated {
+		if coverage, ok := foregroundCoverage(state.FgMask, downscale.Rect(rect, config.DetectionScale)); ok {
+			total += foregroundWeight * coverage
+			weights += foregroundWeight
+		}
+	}
+
+	if weights == 0 {
+		// Nothing to compare against yet, trust the tracker
+		return 1
+	}
+	return total / weights
+}
+
+// foregroundCoverage returns the fraction of rect, given at the mask's scale,
+// that is foreground in the mask
+func foregroundCoverage(mask gocv.Mat, rect image.Rectangle) (float64, bool) {
+	rect = rect.Intersect(image.Rect(0, 0, mask.Cols(), mask.Rows()))
+	if rect.Empty() {
+		return 0, false
+	}
+
+	region := mask.Region(rect)
+	defer func() { _ = region.Close() }()
+
+	return float64(gocv.CountNonZero(region)) / float64(rect.Dx()*rect.Dy()), true
+}
@@ -0,0 +1,115 @@
+package tracking
+
+import (
+	"image"
+	"log"
+
+	"gocv.io/x/gocv"
+
+	"tracker/downscale"
+	"tracker/types"
+	"tracker/utils"
+	"tracker/zones"
+)
+
+// Detection is a candidate object found in a frame. Label and Score are set
+// when it comes from an object detector.
+type Detection struct {
+	Rect  image.Rectangle
+	Area  float64
+	Label string
+	Score float64
+}
+
+// minMotionOverlap is the IoU above which a moving object is considered the
+// same as a detected object when both detection sources are combined
+const minMotionOverlap = 0.3
+
+// DetectObjects returns the candidates for auto-tracking from the configured
+// sources. It reports false on frames where the object detector is due to be
+// skipped and motion detection isn't used alongside it.
+func DetectObjects(state *types.AppState, frame gocv.Mat, config types.TrackingConfig) ([]Detection, bool) {
+	if state.Detector == nil {
+		return DetectMovingObjects(state, config), true
+	}
+
+	if config.DetectorInterval > 1 && state.FrameCount%config.DetectorInterval != 0 {
+		if config.DetectorCombine {
+			return DetectMovingObjects(state, config), true
+		}
+		return nil, false
+	}
+
+	objects, err := state.Detector.Detect(frame)
+	if err != nil {
+		log.Printf("Error running object detector: %v", err)
+		return nil, false
+	}
+
+	// Objects are held to the same minimum size as moving objects
+	var detections []Detection
+	for _, object := range objects {
+		area := float64(object.Rect.Dx() * object.Rect.Dy())
+		if area > config.MinContourArea {
+			detections = append(detections, Detection{Rect: object.Rect, Area: area, Label: object.Label, Score: object.Score})
+		}
+	}
+	if !config.DetectorCombine {
+		return detections, true
+	}
+
+	// Add moving objects the detector doesn't know about
+	objectCount := len(detections)
+	for _, moving := range DetectMovingObjects(state, config) {
+		covered := false
+		for _, detection := range detections[:objectCount] {
+			if utils.IoU(moving.Rect, detection.Rect) > minMotionOverlap {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			detections = append(detections, moving)
+		}
+	}
+	return detections, true
+}
+
+// DetectMovingObjects returns all foreground contours above the minimum area.
+// Contours are found in the mask at the detection scale and returned in
+// working frame coordinates.
+func DetectMovingObjects(state *types.AppState, config types.TrackingConfig) []Detection {
+	contours := gocv.FindContours(state.FgMask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	areaScale := config.DetectionScale * config.DetectionScale
+	var detections []Detection
+	for i := 0; i < contours.Size(); i++ {
+		contour := contours.At(i)
+		area := gocv.ContourArea(contour) / areaScale
+		if area > config.MinContourArea {
+			rect := downscale.Rect(gocv.BoundingRect(contour), 1/config.DetectionScale)
+			detections = append(detections, Detection{Rect: rect, Area: area})
+		}
+	}
+	return detections
+}
+
+// allowedDetections keeps the detections auto-tracking may pick given the detection zones
+func allowedDetections(state *types.AppState, detections []Detection) []Detection {
+	allowed := detections[:0]
+	for _, detection := range detections {
+		if zones.Allows(state.Zones, detection.Rect) {
+			allowed = append(allowed, detection)
+		}
+	}
+	return allowed
+}
+
+// targetPadding is added around a detection when it becomes the tracking ROI
+const targetPadding = 20
+
+// padRect grows a rectangle by padding on every side and clamps it to the frame
+func padRect(rect image.Rectangle, padding int, frame gocv.Mat) image.Rectangle {
+	return rect.Inset(-padding).Intersect(image.Rect(0, 0, frame.Cols(), frame.Rows()))
+}
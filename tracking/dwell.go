@@ -0,0 +1,50 @@
+package tracking
+
+import (
+	"image"
+	"log"
+	"time"
+
+	"tracker/types"
+	"tracker/zones"
+)
+
+// ProcessZoneDwell records when the tracked target and the tracks enter and
+// leave the watch zones, and raises an alert when one of them loiters
+func ProcessZoneDwell(state *types.AppState) {
+	if state.Dwell == nil {
+		return
+	}
+
+	positions := make(map[string]image.Point)
+	for _, object := range countedObjects(state) {
+		positions[object.name] = object.center
+	}
+
+	state.ZoneEvents = state.Dwell.Update(state.Zones, positions, state.FrameTimestamp)
+	logZoneEvents(state.ZoneEvents)
+}
+
+// CloseZoneDwell ends the stays of everyone still inside a watch zone at the
+// end of the session, so that the dwell statistics include them
+func CloseZoneDwell(state *types.AppState) {
+	if state.Dwell == nil {
+		return
+	}
+	state.ZoneEvents = state.Dwell.Close(state.FrameTimestamp)
+	logZoneEvents(state.ZoneEvents)
+}
+
+// logZoneEvents logs zone entries, exits and loitering alerts
+func logZoneEvents(events []zones.Event) {
+	for _, event := range events {
+		switch event.Kind {
+		case zones.Enter:
+			log.Printf("Zone %s entered by %s\n", event.Zone, event.Object)
+		case zones.Leave:
+			log.Printf("Zone %s left by %s after %s\n", event.Zone, event.Object, event.Dwell.Round(100*time.Millisecond))
+		case zones.Loiter:
+			log.Printf("Loitering alert: %s in zone %s for %s\n", event.Object, event.Zone, event.Dwell.Round(100*time.Millisecond))
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package tracking
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"time"
+
+	"gocv.io/x/gocv"
+
+	"tracker/background"
+	"tracker/downscale"
+	"tracker/motion"
+	"tracker/types"
+	"tracker/utils"
+	"tracker/zones"
+)
+
+// BeginFrame resets per-frame tracking state before a new frame is processed
+func BeginFrame(state *types.AppState) {
+	state.ForegroundUpdated = false
+	state.Crossings = nil
+	state.ZoneEvents = nil
+}
+
+// UpdateForeground applies background subtraction and mask cleanup to the frame, at most once per frame
+func UpdateForeground(state *types.AppState, frame gocv.Mat, config types.TrackingConfig) bool {
+	if state.ForegroundUpdated {
+		return true
+	}
+
+	// Subtraction, cleanup and contour finding all run at the detection scale
+	small := frame
+	if config.DetectionScale < 1 {
+		if err := downscale.Resize(frame, &state.DetectionFrame, config.DetectionScale); err != nil {
+			log.Printf("Error downscaling frame for background subtraction: %v", err)
+			return false
+		}
+		small = state.DetectionFrame
+	}
+
+	var err error
+	if state.CameraMotion != nil {
+		err = applyAligned(state, small, config)
+	} else {
+		err = state.BackSub.Apply(small, &state.FgMask)
+	}
+	if err != nil {
+		log.Printf("Error applying background subtractor: %v", err)
+		return false
+	}
+	if err := background.CleanMask(&state.FgMask, config); err != nil {
+		log.Printf("Error cleaning foreground mask: %v", err)
+		return false
+	}
+	if err := zones.MaskExcluded(&state.FgMask, state.Zones, config.DetectionScale); err != nil {
+		log.Printf("Error masking exclude zones: %v", err)
+		return false
+	}
+	state.ForegroundUpdated = true
+	return true
+}
+
+// ProcessMultiTracking detects all moving objects in the frame and associates them with tracks
+func ProcessMultiTracking(state *types.AppState, frame gocv.Mat, config types.TrackingConfig) {
+	if !state.MultiTrackingEnabled || state.FrameCount <= config.StabilizationFrames {
+		return
+	}
+
+	if !UpdateForeground(state, frame, config) {
+		return
+	}
+
+	var rects []image.Rectangle
+	for _, detection := range DetectMovingObjects(state, config) {
+		rects = append(rects, detection.Rect)
+	}
+	UpdateTracks(state, rects, config)
+}
+
+// UpdateTracks matches detections to existing tracks by IoU with Hungarian
+// assignment, advances each track's lifecycle and starts tracks for unmatched detections
+func UpdateTracks(state *types.AppState, detections []image.Rectangle, config types.TrackingConfig) {
+	var assigned []*types.Track
+	state.Tracks, assigned = associateTracks(state.Tracks, detections, &state.NextTrackID, state.FrameTimestamp, config, true)
+
+	for _, track := range assigned {
+		track.History = track.History.Add(track.Center(), state.FrameTimestamp, state.TrajectoryLength)
+		updateSpeed(&track.Speed, track.History, fmt.Sprintf("track %d", track.ID), state.MetersPerPixel, config)
+	}
+}
+
+// SetTrajectoryLength changes how many centers are kept per target, trimming existing trajectories
+func SetTrajectoryLength(state *types.AppState, length int) {
+	state.TrajectoryLength = length
+	state.TargetHistory = state.TargetHistory.Trim(length)
+	for _, track := range state.Tracks {
+		track.History = track.History.Trim(length)
+	}
+}
+
+// associateTracks runs one association step of tracks with detections and
+// returns the updated tracks along with the track each detection was assigned
+// to. New tracks take their IDs from nextID. Lifecycle changes are logged when verbose is set.
+func associateTracks(tracks []*types.Track, detections []image.Rectangle, nextID *int, timestamp time.Duration, config types.TrackingConfig, verbose bool) ([]*types.Track, []*types.Track) {
+	// Drop tracks that were deleted on the previous frame
+	alive := tracks[:0]
+	for _, track := range tracks {
+		if track.State != types.TrackDeleted {
+			alive = append(alive, track)
+		}
+	}
+	tracks = alive
+
+	// Move every track to where its motion model expects it in this frame
+	predicted := make([]image.Rectangle, len(tracks))
+	for i, track := range tracks {
+		predicted[i] = track.Rect
+		if track.Filter != nil {
+			track.Filter.Predict(timestamp)
+			predicted[i] = track.Filter.PredictRect(track.Rect)
+		}
+	}
+
+	// Build the cost matrix, pairs below the IoU gate can't be matched
+	matchedTrack := make([]bool, len(tracks))
+	assigned := make([]*types.Track, len(detections))
+	if len(tracks) > 0 && len(detections) > 0 {
+		cost := make([][]float64, len(tracks))
+		for i := range tracks {
+			cost[i] = make([]float64, len(detections))
+			for j, detection := range detections {
+				cost[i][j] = 1 - utils.IoU(predicted[i], detection)
+			}
+		}
+
+		for i, j := range utils.Hungarian(cost) {
+			if j < 0 || 1-cost[i][j] < config.MinTrackIoU {
+				continue
+			}
+			matchTrack(tracks[i], detections[j], config, verbose)
+			matchedTrack[i] = true
+			assigned[j] = tracks[i]
+		}
+	}
+
+	for i, track := range tracks {
+		if !matchedTrack[i] {
+			// Coast on the prediction until the track is matched again
+			track.Rect = predicted[i]
+			missTrack(track, config, verbose)
+		}
+	}
+
+	for j, detection := range detections {
+		if assigned[j] != nil {
+			continue
+		}
+		*nextID++
+		track := &types.Track{
+			ID:     *nextID,
+			Rect:   detection,
+			State:  types.TrackTentative,
+			Hits:   1,
+			Filter: motion.NewKalman(utils.RectCenter(detection), timestamp, config.KalmanAccelNoise, config.KalmanMeasurementNoise),
+		}
+		tracks = append(tracks, track)
+		assigned[j] = track
+	}
+	return tracks, assigned
+}
+
+// matchTrack updates a track with its matched detection
+func matchTrack(track *types.Track, detection image.Rectangle, config types.TrackingConfig, verbose bool) {
+	missed := track.Misses
+	track.Rect = detection
+	if track.Filter != nil {
+		track.Filter.Correct(utils.RectCenter(detection))
+	}
+	track.Hits++
+	track.Misses = 0
+	track.Age++
+
+	switch track.State {
+	case types.TrackTentative:
+		if track.Hits >= config.TrackConfirmHits {
+			track.State = types.TrackConfirmed
+			if verbose {
+				log.Printf("Track %d confirmed", track.ID)
+			}
+		}
+	case types.TrackLost:
+		track.State = types.TrackConfirmed
+		if verbose {
+			log.Printf("Track %d re-acquired after %d frames", track.ID, missed)
+		}
+	}
+}
+
+// missTrack updates a track that had no matching detection
+func missTrack(track *types.Track, config types.TrackingConfig, verbose bool) {
+	track.Hits = 0
+	track.Misses++
+	track.Age++
+
+	switch track.State {
+	case types.TrackTentative:
+		// Unconfirmed tracks are usually noise, drop them right away
+		track.State = types.TrackDeleted
+	case types.TrackConfirmed:
+		track.State = types.TrackLost
+	case types.TrackLost:
+		if track.Misses > config.TrackMaxMisses {
+			track.State = types.TrackDeleted
+			if verbose {
+				log.Printf("Track %d deleted", track.ID)
+			}
+		}
+	}
+}
+
+// ResetMultiTracking removes all tracks
+func ResetMultiTracking(state *types.AppState) {
+	state.Tracks = nil
+}
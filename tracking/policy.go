@@ -0,0 +1,189 @@
+package tracking
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"gocv.io/x/gocv"
+
+	"tracker/types"
+	"tracker/utils"
+)
+
+// candidate is a moving object visible in the current frame together with the
+// track that follows it across the frames auto-tracking has been searching
+type candidate struct {
+	Detection
+	track *types.Track
+}
+
+// selectionPolicy picks the auto-tracking target among the visible candidates,
+// or reports false to keep waiting for a suitable one
+type selectionPolicy func(state *types.AppState, frame gocv.Mat, candidates []candidate, config types.TrackingConfig) (candidate, bool)
+
+// policyRegistry lists the target selection policies in hotkey cycling order
+var policyRegistry = []struct {
+	name   string
+	choose selectionPolicy
+}{
+	{name: "largest", choose: selectLargest},
+	{name: "center", choose: selectClosestToCenter},
+	{name: "last-position", choose: selectClosestToLastPosition},
+	{name: "persistent", choose: selectMostPersistent},
+	{name: "fastest", choose: selectFastest},
+	{name: "first", choose: selectFirstToEnter},
+}
+
+// PolicyNames returns the names of the target selection policies
+func PolicyNames() []string {
+	names := make([]string, len(policyRegistry))
+	for i, policy := range policyRegistry {
+		names[i] = policy.name
+	}
+	return names
+}
+
+// ParsePolicy returns the canonical name of a target selection policy (case-insensitive)
+func ParsePolicy(name string) (string, error) {
+	for _, policy := range policyRegistry {
+		if strings.EqualFold(policy.name, name) {
+			return policy.name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown target policy %q, available: %s", name, strings.Join(PolicyNames(), ", "))
+}
+
+// NextPolicyName returns the policy after current in cycling order
+func NextPolicyName(current string) string {
+	for i, policy := range policyRegistry {
+		if policy.name == current {
+			return policyRegistry[(i+1)%len(policyRegistry)].name
+		}
+	}
+	return policyRegistry[0].name
+}
+
+// lookupPolicy returns the named policy, falling back to the largest object
+func lookupPolicy(name string) selectionPolicy {
+	for _, policy := range policyRegistry {
+		if policy.name == name {
+			return policy.choose
+		}
+	}
+	return selectLargest
+}
+
+// trackCandidates follows the detections of this frame across frames and
+// returns them as candidates with their history
+func trackCandidates(state *types.AppState, detections []Detection, config types.TrackingConfig) []candidate {
+	rects := make([]image.Rectangle, len(detections))
+	for i, detection := range detections {
+		rects[i] = detection.Rect
+	}
+
+	var assigned []*types.Track
+	state.Candidates, assigned = associateTracks(state.Candidates, rects, &state.NextCandidateID, state.FrameTimestamp, config, false)
+
+	candidates := make([]candidate, len(detections))
+	for i, detection := range detections {
+		candidates[i] = candidate{Detection: detection, track: assigned[i]}
+	}
+	return candidates
+}
+
+// selectLargest picks the candidate with the largest contour area
+func selectLargest(_ *types.AppState, _ gocv.Mat, candidates []candidate, _ types.TrackingConfig) (candidate, bool) {
+	if len(candidates) == 0 {
+		return candidate{}, false
+	}
+
+	largest := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.Area > largest.Area {
+			largest = c
+		}
+	}
+	return largest, true
+}
+
+// selectClosestToCenter picks the candidate nearest to the center of the frame
+func selectClosestToCenter(_ *types.AppState, frame gocv.Mat, candidates []candidate, _ types.TrackingConfig) (candidate, bool) {
+	return closestTo(image.Pt(frame.Cols()/2, frame.Rows()/2), candidates)
+}
+
+// selectClosestToLastPosition picks the candidate nearest to where the previous
+// target was last seen, or to the frame center when there was none
+func selectClosestToLastPosition(state *types.AppState, frame gocv.Mat, candidates []candidate, config types.TrackingConfig) (candidate, bool) {
+	if state.LastKnownRect.Empty() {
+		return selectClosestToCenter(state, frame, candidates, config)
+	}
+	return closestTo(utils.RectCenter(state.LastKnownRect), candidates)
+}
+
+// selectMostPersistent picks the candidate seen in the most consecutive frames,
+// once one has been seen for at least PolicyPersistenceFrames
+func selectMostPersistent(_ *types.AppState, _ gocv.Mat, candidates []candidate, config types.TrackingConfig) (candidate, bool) {
+	var best candidate
+	found := false
+	for _, c := range candidates {
+		if c.track.Hits < config.PolicyPersistenceFrames {
+			continue
+		}
+		if !found || c.track.Hits > best.track.Hits || (c.track.Hits == best.track.Hits && c.Area > best.Area) {
+			best = c
+			found = true
+		}
+	}
+	return best, found
+}
+
+// selectFastest picks the fastest moving candidate among those followed long
+// enough to have a reliable velocity
+func selectFastest(_ *types.AppState, _ gocv.Mat, candidates []candidate, config types.TrackingConfig) (candidate, bool) {
+	var best candidate
+	bestSpeed := -1.0
+	for _, c := range candidates {
+		if c.track.Hits < config.TrackConfirmHits || c.track.Filter == nil {
+			continue
+		}
+		vx, vy := c.track.Filter.Velocity()
+		if speed := math.Hypot(vx, vy); speed > bestSpeed {
+			bestSpeed = speed
+			best = c
+		}
+	}
+	return best, bestSpeed >= 0
+}
+
+// selectFirstToEnter picks the earliest seen candidate among those followed
+// long enough to be trusted
+func selectFirstToEnter(_ *types.AppState, _ gocv.Mat, candidates []candidate, config types.TrackingConfig) (candidate, bool) {
+	var best candidate
+	found := false
+	for _, c := range candidates {
+		if c.track.Hits < config.TrackConfirmHits {
+			continue
+		}
+		if !found || c.track.ID < best.track.ID {
+			best = c
+			found = true
+		}
+	}
+	return best, found
+}
+
+// closestTo returns the candidate whose center is nearest to p
+func closestTo(p image.Point, candidates []candidate) (candidate, bool) {
+	var best candidate
+	bestDistance := math.Inf(1)
+	for _, c := range candidates {
+		center := utils.RectCenter(c.Rect)
+		if distance := math.Hypot(float64(center.X-p.X), float64(center.Y-p.Y)); distance < bestDistance {
+			bestDistance = distance
+			best = c
+		}
+	}
+	return best, !math.IsInf(bestDistance, 1)
+}
@@ -0,0 +1,94 @@
+package tracking
+
+import (
+	"image"
+	"log"
+
+	"gocv.io/x/gocv"
+
+	"tracker/types"
+)
+
+// minPatchSize is the smallest side a scaled target patch may have to still be matched
+const minPatchSize = 8
+
+// TryTrackingRecovery searches for the last good target patch around the
+// expected target position at each of the configured scales. The tracker is
+// re-initialized on the best match only when it scores at least
+// RecoveryMatchThreshold. It returns the best match and its score either way.
+func TryTrackingRecovery(frame gocv.Mat, tracker gocv.Tracker, patch gocv.Mat, expected image.Rectangle, config types.TrackingConfig) (image.Rectangle, float64, bool) {
+	if patch.Empty() {
+		return image.Rectangle{}, 0, false
+	}
+
+	// Search the expected box widened by the search radius on every side
+	window := padRect(expected, config.SearchRadius, frame)
+	if window.Empty() {
+		return image.Rectangle{}, 0, false
+	}
+
+	region := frame.Region(window)
+	defer func() { _ = region.Close() }()
+
+	gray := gocv.NewMat()
+	defer func() { _ = gray.Close() }()
+	if err := gocv.CvtColor(region, &gray, gocv.ColorBGRToGray); err != nil {
+		log.Printf("Error converting recovery window to grayscale: %v", err)
+		return image.Rectangle{}, 0, false
+	}
+
+	scaled := gocv.NewMat()
+	defer func() { _ = scaled.Close() }()
+
+	result := gocv.NewMat()
+	defer func() { _ = result.Close() }()
+
+	mask := gocv.NewMat()
+	defer func() { _ = mask.Close() }()
+
+	bestScore := -1.0
+	var best image.Rectangle
+	for _, scale := range config.RecoveryScales {
+		size := image.Pt(int(float64(patch.Cols())*scale), int(float64(patch.Rows())*scale))
+		if size.X < minPatchSize || size.Y < minPatchSize || size.X > window.Dx() || size.Y > window.Dy() {
+			continue
+		}
+
+		if err := gocv.Resize(patch, &scaled, size, 0, 0, gocv.InterpolationLinear); err != nil {
+			log.Printf("Error scaling target patch: %v", err)
+			continue
+		}
+		if err := gocv.MatchTemplate(gray, scaled, &result, gocv.TmCcoeffNormed, mask); err != nil {
+			log.Printf("Error matching target patch: %v", err)
+			continue
+		}
+
+		_, score, _, loc := gocv.MinMaxLoc(result)
+		if float64(score) > bestScore {
+			bestScore = float64(score)
+			topLeft := window.Min.Add(loc)
+			best = image.Rectangle{Min: topLeft, Max: topLeft.Add(size)}
+		}
+	}
+
+	if best.Empty() || bestScore < config.RecoveryMatchThreshold {
+		return best, bestScore, false
+	}
+	return best, bestScore, tracker.Init(frame, best)
+}
+
+// captureTargetPatch keeps the grayscale region rect of frame as the last good
+// appearance of the target for recovery
+func captureTargetPatch(state *types.AppState, frame gocv.Mat, rect image.Rectangle) {
+	rect = rect.Intersect(image.Rect(0, 0, frame.Cols(), frame.Rows()))
+	if rect.Empty() {
+		return
+	}
+
+	region := frame.Region(rect)
+	defer func() { _ = region.Close() }()
+
+	if err := gocv.CvtColor(region, &state.TargetPatch, gocv.ColorBGRToGray); err != nil {
+		log.Printf("Error capturing target patch: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package tracking
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"strings"
+
+	"gocv.io/x/gocv"
+	"gocv.io/x/gocv/contrib"
+
+	"tracker/downscale"
+	"tracker/types"
+)
+
+// TrackerFactory creates a new instance of a tracking algorithm
+type TrackerFactory func() gocv.Tracker
+
+// trackerAlgorithm is a registered tracking algorithm
+type trackerAlgorithm struct {
+	name    string
+	factory TrackerFactory
+	// available reports whether the algorithm can be created on this machine
+	available func() bool
+}
+
+// trackerRegistry lists the tracking algorithms in hotkey cycling order.
+// MOSSE, Boosting, MedianFlow and TLD are not exposed by gocv, so they can't be offered here.
+var trackerRegistry = []trackerAlgorithm{
+	{name: "CSRT", factory: contrib.NewTrackerCSRT},
+	{name: "KCF", factory: contrib.NewTrackerKCF},
+	{name: "MIL", factory: gocv.NewTrackerMIL},
+	{
+		name:    "GOTURN",
+		factory: func() gocv.Tracker { return gocv.NewTrackerGOTURN() },
+		// GOTURN loads its network from the working directory and aborts if it is missing
+		available: func() bool { return fileExists("goturn.prototxt") && fileExists("goturn.caffemodel") },
+	},
+}
+
+// fileExists reports whether a regular file exists at path
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// TrackerNames returns the names of the tracking algorithms available on this machine
+func TrackerNames() []string {
+	var names []string
+	for _, algorithm := range trackerRegistry {
+		if algorithm.available == nil || algorithm.available() {
+			names = append(names, algorithm.name)
+		}
+	}
+	return names
+}
+
+// NewTracker creates a tracker by algorithm name (case-insensitive) that runs
+// on frames downscaled by scale
+func NewTracker(name string, scale float64) (gocv.Tracker, string, error) {
+	for _, algorithm := range trackerRegistry {
+		if !strings.EqualFold(algorithm.name, name) {
+			continue
+		}
+		if algorithm.available != nil && !algorithm.available() {
+			return nil, "", fmt.Errorf("tracker %s is not available (missing model files?)", algorithm.name)
+		}
+		return downscale.Tracker(algorithm.factory(), scale), algorithm.name, nil
+	}
+	return nil, "", fmt.Errorf("unknown tracker %q, available: %s", name, strings.Join(TrackerNames(), ", "))
+}
+
+// NextTrackerName returns the available algorithm after current in cycling order
+func NextTrackerName(current string) string {
+	names := TrackerNames()
+	for i, name := range names {
+		if strings.EqualFold(name, current) {
+			return names[(i+1)%len(names)]
+		}
+	}
+	return names[0]
+}
+
+// SwitchTracker replaces the active tracker with the named algorithm. An
+// active target is handed over by initializing the new tracker on its last
+// known position; if that fails the old tracker is kept.
+func SwitchTracker(state *types.AppState, frame gocv.Mat, name string, config types.TrackingConfig) error {
+	tracker, canonicalName, err := NewTracker(name, config.TrackingScale)
+	if err != nil {
+		return err
+	}
+
+	if state.Mode.Current().HasTarget() {
+		rect := state.LastKnownRect
+		if rect.Empty() {
+			rect = state.ROI
+		}
+		rect = rect.Intersect(image.Rect(0, 0, frame.Cols(), frame.Rows()))
+
+		if rect.Empty() || !tracker.Init(frame, rect) {
+			_ = tracker.Close()
+			return fmt.Errorf("could not hand target over to %s, keeping %s", canonicalName, state.TrackerName)
+		}
+	}
+
+	if state.Tracker != nil {
+		_ = state.Tracker.Close()
+	}
+	state.Tracker = tracker
+	state.TrackerName = canonicalName
+	resetTrackerShift(state)
+	log.Printf("Tracker switched to %s", canonicalName)
+	return nil
+}
@@ -0,0 +1,28 @@
+package tracking
+
+import (
+	"log"
+
+	"tracker/types"
+)
+
+// updateSpeed refreshes a smoothed speed from the trajectory of a target and
+// logs an alert when it rises above the speed limit
+func updateSpeed(speed *types.Speed, trajectory types.Trajectory, name string, metersPerPixel float64, config types.TrackingConfig) {
+	vx, vy, ok := trajectory.Velocity(config.SpeedWindow)
+	if !ok {
+		return
+	}
+	speed.Update(vx, vy, config.SpeedSmoothing)
+
+	// The limit is in m/s, so it needs a scale
+	if config.SpeedLimit <= 0 || metersPerPixel <= 0 {
+		speed.Exceeded = false
+		return
+	}
+	exceeded := speed.PixelsPerSecond()*metersPerPixel > config.SpeedLimit
+	if exceeded && !speed.Exceeded {
+		log.Printf("Speed alert: %s at %s exceeds %.1f m/s\n", name, speed.Format(metersPerPixel), config.SpeedLimit)
+	}
+	speed.Exceeded = exceeded
+}
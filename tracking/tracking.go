@@ -1,121 +1,141 @@
 package tracking
 
 import (
+	"fmt"
 	"image"
 	"log"
 
 	"gocv.io/x/gocv"
 
+	"tracker/fsm"
+	"tracker/motion"
+	"tracker/reid"
 	"tracker/types"
 	"tracker/utils"
 )
 
-// TryTrackingRecovery attempts to recover lost tracking by searching around the last known position
-func TryTrackingRecovery(frame gocv.Mat, tracker gocv.Tracker, lastRect image.Rectangle, searchRadius int) bool {
-	// Expand search area around last known position
-	centerX := lastRect.Min.X + lastRect.Dx()/2
-	centerY := lastRect.Min.Y + lastRect.Dy()/2
-
-	// Create expanded search rectangle
-	searchRect := image.Rect(
-		centerX-searchRadius,
-		centerY-searchRadius,
-		centerX+searchRadius,
-		centerY+searchRadius,
-	)
-
-	// Ensure search area is within frame bounds
-	if searchRect.Min.X < 0 {
-		searchRect.Min.X = 0
-	}
-	if searchRect.Min.Y < 0 {
-		searchRect.Min.Y = 0
-	}
-	if searchRect.Max.X > frame.Cols() {
-		searchRect.Max.X = frame.Cols()
-	}
-	if searchRect.Max.Y > frame.Rows() {
-		searchRect.Max.Y = frame.Rows()
+// ProcessAutoTracking handles automatic object detection and tracking initialization
+func ProcessAutoTracking(state *types.AppState, frame gocv.Mat, config types.TrackingConfig) {
+	if !state.Mode.Current().Acquiring() || state.FrameCount <= config.StabilizationFrames {
+		return
 	}
 
-	// Try to reinitialize tracker with expanded area
-	return tracker.Init(frame, searchRect)
-}
+	if !UpdateForeground(state, frame, config) {
+		return
+	}
 
-// ProcessAutoTracking handles automatic object detection and tracking initialization
-func ProcessAutoTracking(state *types.AppState, frame gocv.Mat, config types.TrackingConfig) {
-	if state.TrackingEnabled || !state.AutoTrackingEnabled || state.ROISelectionMode || state.FrameCount <= config.StabilizationFrames {
+	// Find objects large enough to track and follow them while searching
+	detections, ok := DetectObjects(state, frame, config)
+	if !ok {
+		return
+	}
+	candidates := trackCandidates(state, allowedDetections(state, detections), config)
+	if len(candidates) == 0 {
 		return
 	}
 
-	if err := state.BackSub.Apply(frame, &state.FgMask); err != nil {
-		log.Printf("Error applying background subtractor: %v", err)
+	// Prefer the previous target if it shows up again, otherwise apply the selection policy
+	chosen, similarity, reidentified, ok := selectAutoTrackingTarget(state, frame, candidates, config)
+	if !ok {
 		return
 	}
 
-	// Find contours of moving objects
-	contours := gocv.FindContours(state.FgMask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	roi := padRect(chosen.Rect, targetPadding, frame)
+	if state.Tracker.Init(frame, roi) {
+		state.Candidates = nil
+		state.TargetLabel = chosen.Label
+		state.ROI = roi
+		state.TargetSize = utils.NewSizeReference(roi)
+		state.LastKnownRect = roi
+		resetTargetMotion(state)
+		captureTargetPatch(state, frame, roi)
 
-	var largestContourIndex = -1
-	var largestArea float64
+		var reason string
+		if reidentified {
+			reason = fmt.Sprintf("auto-tracking re-identified target (similarity %.2f), ROI %dx%d at (%d,%d)", similarity, roi.Dx(), roi.Dy(), roi.Min.X, roi.Min.Y)
+		} else {
+			captureAppearance(state, frame, roi)
+			reason = fmt.Sprintf("auto-tracking started on new %s (%s policy), ROI %dx%d at (%d,%d)", targetDescription(chosen), state.TargetPolicy, roi.Dx(), roi.Dy(), roi.Min.X, roi.Min.Y)
+		}
+		transition(state, fsm.Tracking, reason)
+	}
+}
+
+// selectAutoTrackingTarget picks the candidate to start tracking. A candidate
+// that matches the appearance of the previous target above the re-identification
+// threshold wins; otherwise the active selection policy decides, which may
+// choose to wait.
+func selectAutoTrackingTarget(state *types.AppState, frame gocv.Mat, candidates []candidate, config types.TrackingConfig) (candidate, float64, bool, bool) {
+	bestScore := -1.0
+	if state.TargetAppearance != nil && config.ReIDThreshold > 0 {
+		var best candidate
+		for _, c := range candidates {
+			score, err := state.TargetAppearance.Similarity(frame, padRect(c.Rect, targetPadding, frame))
+			if err != nil {
+				continue
+			}
+			if score > bestScore {
+				bestScore = score
+				best = c
+			}
+		}
 
-	for i := 0; i < contours.Size(); i++ {
-		contour := contours.At(i)
-		area := gocv.ContourArea(contour)
-		if area > largestArea && area > config.MinContourArea {
-			largestArea = area
-			largestContourIndex = i
+		if bestScore >= config.ReIDThreshold {
+			return best, bestScore, true, true
 		}
 	}
 
-	if largestContourIndex >= 0 {
-		largestContour := contours.At(largestContourIndex)
-		roi := gocv.BoundingRect(largestContour)
+	chosen, ok := lookupPolicy(state.TargetPolicy)(state, frame, candidates, config)
+	return chosen, bestScore, false, ok
+}
 
-		// Add padding to the bounding box
-		padding := 20
-		roi = image.Rect(
-			roi.Min.X-padding,
-			roi.Min.Y-padding,
-			roi.Max.X+padding,
-			roi.Max.Y+padding,
-		)
+// targetDescription names a new target by its detected class
+func targetDescription(c candidate) string {
+	if c.Label == "" {
+		return "target"
+	}
+	return fmt.Sprintf("%s target (score %.2f)", c.Label, c.Score)
+}
 
-		// Ensure ROI is within image bounds
-		if roi.Min.X < 0 {
-			roi.Min.X = 0
-		}
-		if roi.Min.Y < 0 {
-			roi.Min.Y = 0
-		}
-		if roi.Max.X > frame.Cols() {
-			roi.Max.X = frame.Cols()
-		}
-		if roi.Max.Y > frame.Rows() {
-			roi.Max.Y = frame.Rows()
-		}
+// captureAppearance replaces the stored target appearance with the given region
+func captureAppearance(state *types.AppState, frame gocv.Mat, roi image.Rectangle) {
+	model, err := reid.NewModel(frame, roi)
+	if err != nil {
+		log.Printf("Error capturing target appearance: %v", err)
+		return
+	}
 
-		if state.Tracker.Init(frame, roi) {
-			state.ROI = roi
-			state.TrackingEnabled = true
-			state.AutoTrackingEnabled = false
-			state.InitialROISize = (roi.Dx() + roi.Dy()) / 2
-			log.Printf("Auto-tracking started! ROI: %dx%d at (%d,%d)\n", roi.Dx(), roi.Dy(), roi.Min.X, roi.Min.Y)
-		}
+	clearAppearance(state)
+	state.TargetAppearance = model
+}
+
+// clearAppearance forgets the stored target appearance
+func clearAppearance(state *types.AppState) {
+	if state.TargetAppearance != nil {
+		_ = state.TargetAppearance.Close()
+		state.TargetAppearance = nil
 	}
 }
 
 // ProcessTracking handles active object tracking and failure recovery
 func ProcessTracking(state *types.AppState, frame gocv.Mat, config types.TrackingConfig) image.Rectangle {
-	if !state.TrackingEnabled {
+	if !state.Mode.Current().HasTarget() {
 		return image.Rectangle{}
 	}
 
+	// Advance the motion model to this frame
+	if state.TargetFilter != nil {
+		state.TargetFilter.Predict(state.FrameTimestamp)
+	}
+
+	// The confidence score includes the foreground coverage of the box; the
+	// mask is computed once here and reused by multi-object tracking
+	UpdateForeground(state, frame, config)
+
+	state.TargetConfidence = 0
+	failure := "tracker update failed"
 	rect, ok := state.Tracker.Update(frame)
 	if ok {
-		// Successful tracking - reset failure count
-		state.TrackingFailureCount = 0
-
 		// Check for dramatic size changes that might indicate target switching
 		currentSize := (rect.Dx() + rect.Dy()) / 2
 		if !state.LastKnownRect.Empty() {
@@ -125,41 +145,65 @@ func ProcessTracking(state *types.AppState, frame gocv.Mat, config types.Trackin
 				// Suspect target switching - use last known position and increment failure count
 				log.Printf("Suspicious size change detected (ratio: %.2f), using last known position\n", sizeRatio)
 				state.TrackingFailureCount++
+				transition(state, fsm.Occluded, fmt.Sprintf("suspicious size change (ratio %.2f)", sizeRatio))
 				rect = state.LastKnownRect
 				return rect
 			}
 		}
 
 		// Apply adaptive bounding box size control
-		rect = utils.ConstrainBoundingBox(rect, state.InitialROISize, config.MaxROIGrowth, config.MinROISize, frame.Cols(), frame.Rows())
-		state.LastKnownRect = rect
-		return rect
+		measured := rect
+		limits := utils.SizeLimits{MaxGrowth: config.MaxROIGrowth, MaxStep: config.MaxSizeStep, MinSize: config.MinROISize}
+		rect = utils.ConstrainBoundingBox(rect, state.LastKnownRect, state.TargetSize, limits, frame.Cols(), frame.Rows())
+
+		// A tracker that reports success on something that no longer looks like the target is treated as failing
+		state.TargetConfidence = ScoreConfidence(state, frame, rect, config)
+		if state.TargetConfidence >= config.MinConfidence {
+			// Successful tracking - reset failure count
+			state.TrackingFailureCount = 0
+			state.LastKnownRect = rect
+			state.TargetSize = state.TargetSize.Adapt(measured, config.SizeAdaptRate)
+			correctTargetMotion(state, rect, config)
+			captureTargetPatch(state, frame, rect)
+			transition(state, fsm.Tracking, fmt.Sprintf("target reacquired (confidence %.2f)", state.TargetConfidence))
+			return rect
+		}
+		failure = fmt.Sprintf("low confidence %.2f (min %.2f)", state.TargetConfidence, config.MinConfidence)
 	}
 
 	// Tracking failed - increment failure count and try recovery
 	state.TrackingFailureCount++
-	log.Printf("Tracking failure %d/%d\n", state.TrackingFailureCount, config.MaxTrackingFailures)
+	log.Printf("Tracking failure %d/%d: %s\n", state.TrackingFailureCount, config.MaxTrackingFailures, failure)
 
 	if state.TrackingFailureCount >= config.MaxTrackingFailures {
-		// Too many failures, give up and re-enable auto-tracking
-		state.TrackingEnabled = false
+		// Too many failures, give up and let auto-tracking search for the target again
 		state.TrackingFailureCount = 0
-		if !state.ROISelectionMode {
-			state.AutoTrackingEnabled = true
-			log.Println("Tracking lost permanently. Re-enabling auto-tracking...")
-		} else {
-			log.Println("Tracking lost permanently.")
-		}
+		state.TargetConfidence = 0
+		resetTargetMotion(state)
+		transition(state, fsm.Lost, fmt.Sprintf("%d consecutive tracking failures", config.MaxTrackingFailures))
 		return image.Rectangle{}
 	}
+	transition(state, fsm.Occluded, failure)
 
 	if !state.LastKnownRect.Empty() {
-		// Try to recover tracking using last known position
-		if TryTrackingRecovery(frame, state.Tracker, state.LastKnownRect, config.SearchRadius) {
-			log.Printf("Tracking recovery successful at attempt %d\n", state.TrackingFailureCount)
+		// Search where the target is expected to be by now, not where it was last seen
+		expectedRect := predictTargetRect(state, frame)
+
+		// Look for the last good view of the target around the expected position
+		match, score, recovered := TryTrackingRecovery(frame, state.Tracker, state.TargetPatch, expectedRect, config)
+		if recovered {
 			state.TrackingFailureCount = 0
+			state.TargetConfidence = score
+			state.LastKnownRect = match
+			resetTrackerShift(state)
+			correctTargetMotion(state, match, config)
+			transition(state, fsm.Tracking, fmt.Sprintf("recovered by template match (score %.2f)", score))
+			return match
 		}
-		return state.LastKnownRect
+		if !match.Empty() {
+			log.Printf("Recovery match %.2f below threshold %.2f\n", score, config.RecoveryMatchThreshold)
+		}
+		return expectedRect
 	}
 
 	return image.Rectangle{}
@@ -169,28 +213,106 @@ func ProcessTracking(state *types.AppState, frame gocv.Mat, config types.Trackin
 func InitializeTracking(state *types.AppState, frame gocv.Mat, roi image.Rectangle) bool {
 	if state.Tracker.Init(frame, roi) {
 		state.ROI = roi
-		state.TrackingEnabled = true
-		state.ROISelectionMode = false
-		state.AutoTrackingEnabled = false
-		state.InitialROISize = (roi.Dx() + roi.Dy()) / 2
+		state.TargetSize = utils.NewSizeReference(roi)
+		state.LastKnownRect = roi
+		state.TargetLabel = ""
+		resetTargetMotion(state)
+		captureAppearance(state, frame, roi)
+		captureTargetPatch(state, frame, roi)
+		transition(state, fsm.Tracking, fmt.Sprintf("manual ROI %dx%d at (%d,%d)", roi.Dx(), roi.Dy(), roi.Min.X, roi.Min.Y))
 		return true
 	}
 	return false
 }
 
+// correctTargetMotion feeds a confirmed target position into the motion model,
+// starting a new model for a newly acquired target
+func correctTargetMotion(state *types.AppState, rect image.Rectangle, config types.TrackingConfig) {
+	state.PredictedRect = image.Rectangle{}
+
+	center := utils.RectCenter(rect)
+	state.TargetHistory = state.TargetHistory.Add(center, state.FrameTimestamp, state.TrajectoryLength)
+	updateSpeed(&state.TargetSpeed, state.TargetHistory, "target", state.MetersPerPixel, config)
+	if state.TargetFilter == nil {
+		state.TargetFilter = motion.NewKalman(center, state.FrameTimestamp, config.KalmanAccelNoise, config.KalmanMeasurementNoise)
+		return
+	}
+	state.TargetFilter.Correct(center)
+}
+
+// predictTargetRect returns the last known target box moved to the position
+// predicted by the motion model, kept within the frame
+func predictTargetRect(state *types.AppState, frame gocv.Mat) image.Rectangle {
+	if state.TargetFilter == nil {
+		return state.LastKnownRect
+	}
+
+	predicted := state.TargetFilter.PredictRect(state.LastKnownRect)
+	predicted = predicted.Intersect(image.Rect(0, 0, frame.Cols(), frame.Rows()))
+	if predicted.Empty() {
+		// Predicted to have left the frame, keep searching at the edge it was last seen
+		return state.LastKnownRect
+	}
+
+	state.PredictedRect = predicted
+	return predicted
+}
+
+// resetTargetMotion discards the motion model and trajectory of the previous target
+func resetTargetMotion(state *types.AppState) {
+	state.TargetFilter = nil
+	state.PredictedRect = image.Rectangle{}
+	state.TargetHistory = nil
+	state.TargetSpeed = types.Speed{}
+	resetTrackerShift(state)
+}
+
 // ResetTracking resets all tracking state
 func ResetTracking(state *types.AppState) {
-	state.TrackingEnabled = false
-	state.AutoTrackingEnabled = true
-	state.ROISelectionMode = false
+	transition(state, fsm.Searching, "tracking reset")
 	state.FrameCount = 0
+	state.TrackingFailureCount = 0
+	state.LastKnownRect = image.Rectangle{}
+	state.TargetLabel = ""
+	state.Candidates = nil
+	resetTargetMotion(state)
+	clearAppearance(state)
+	ResetMultiTracking(state)
 }
 
 // EnableAutoTracking starts auto-tracking mode
 func EnableAutoTracking(state *types.AppState) {
-	if !state.ROISelectionMode {
-		state.AutoTrackingEnabled = true
-		state.TrackingEnabled = false
+	if !state.Mode.Is(fsm.Selecting) {
+		transition(state, fsm.Searching, "auto-tracking enabled")
 		state.FrameCount = 0
+		state.TrackingFailureCount = 0
+		state.Candidates = nil
+	}
+}
+
+// DisableAutoTracking stops looking for a target
+func DisableAutoTracking(state *types.AppState) {
+	transition(state, fsm.Idle, "auto-tracking disabled")
+}
+
+// StartROISelection switches to manual ROI selection, dropping any current target
+func StartROISelection(state *types.AppState) {
+	transition(state, fsm.Selecting, "manual ROI selection started")
+	state.TrackingFailureCount = 0
+}
+
+// CancelROISelection leaves manual ROI selection and goes back to auto-tracking
+func CancelROISelection(state *types.AppState, reason string) {
+	if transition(state, fsm.Searching, reason) {
+		state.FrameCount = 0
+	}
+}
+
+// transition moves the tracking state machine, logging transitions it rejects
+func transition(state *types.AppState, to fsm.State, reason string) bool {
+	if err := state.Mode.To(to, reason); err != nil {
+		log.Printf("Ignoring %v\n", err)
+		return false
 	}
+	return true
 }
@@ -0,0 +1,72 @@
+package tracking
+
+import (
+	"fmt"
+	"image"
+	"log"
+
+	"tracker/tripwire"
+	"tracker/types"
+	"tracker/utils"
+)
+
+// countedObject is a target or track as seen by tripwires and watch zones
+type countedObject struct {
+	name    string
+	center  image.Point
+	history types.Trajectory
+}
+
+// countedObjects returns the objects tripwires and watch zones count, each
+// person only once. With multi-object tracking on, the tracks already cover
+// the target, so it isn't counted separately. Tentative tracks come and go
+// with noise and are left out.
+func countedObjects(state *types.AppState) []countedObject {
+	if !state.MultiTrackingEnabled {
+		if !state.Mode.Current().HasTarget() || state.LastKnownRect.Empty() {
+			return nil
+		}
+		return []countedObject{{name: "target", center: utils.RectCenter(state.LastKnownRect), history: state.TargetHistory}}
+	}
+
+	var objects []countedObject
+	for _, track := range state.Tracks {
+		if track.State == types.TrackConfirmed || track.State == types.TrackLost {
+			objects = append(objects, countedObject{name: fmt.Sprintf("track %d", track.ID), center: track.Center(), history: track.History})
+		}
+	}
+	return objects
+}
+
+// ProcessTripwires checks whether the tracked target or any track crossed a
+// tripwire line and counts the crossings
+func ProcessTripwires(state *types.AppState, config types.TrackingConfig) {
+	if len(state.Tripwires) == 0 {
+		return
+	}
+
+	for _, object := range countedObjects(state) {
+		checkTripwires(state, object.name, object.history, config)
+	}
+}
+
+// checkTripwires follows the latest position of a trajectory across every
+// line, if the object was seen in this frame
+func checkTripwires(state *types.AppState, object string, trajectory types.Trajectory, config types.TrackingConfig) {
+	n := len(trajectory)
+	if n == 0 || trajectory[n-1].Time != state.FrameTimestamp {
+		return
+	}
+	center := trajectory[n-1].Center
+
+	for i := range state.Tripwires {
+		line := &state.Tripwires[i]
+		direction, crossed := line.Track(object, center, state.FrameTimestamp, config.TripwireMinDistance, config.TripwireCooldown)
+		if !crossed {
+			continue
+		}
+		line.Count(direction)
+		state.Crossings = append(state.Crossings, tripwire.Crossing{Line: line.Name, Object: object, Direction: direction})
+		log.Printf("Tripwire %s crossed %s by %s (in %d, out %d)\n", line.Name, direction, object, line.In, line.Out)
+	}
+}
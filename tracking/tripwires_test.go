@@ -0,0 +1,91 @@
+package tracking
+
+import (
+	"image"
+	"reflect"
+	"testing"
+	"time"
+
+	"tracker/fsm"
+	"tracker/tripwire"
+	"tracker/types"
+)
+
+func TestCountedObjects(t *testing.T) {
+	tracks := []*types.Track{
+		{ID: 1, Rect: image.Rect(100, 100, 140, 200), State: types.TrackConfirmed},
+		{ID: 2, Rect: image.Rect(300, 100, 340, 200), State: types.TrackTentative},
+		{ID: 3, Rect: image.Rect(500, 100, 540, 200), State: types.TrackLost},
+		{ID: 4, Rect: image.Rect(0, 0, 10, 10), State: types.TrackDeleted},
+	}
+	target := image.Rect(95, 95, 145, 205)
+
+	tests := []struct {
+		name      string
+		hasTarget bool
+		multi     bool
+		want      []string
+	}{
+		{"target only", true, false, []string{"target"}},
+		{"no target", false, false, nil},
+		{"target covered by its track", true, true, []string{"track 1", "track 3"}},
+		{"tracks only", false, true, []string{"track 1", "track 3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &types.AppState{Tracks: tracks, MultiTrackingEnabled: tt.multi, LastKnownRect: target}
+			if tt.hasTarget {
+				_ = state.Mode.To(fsm.Searching, "test")
+				_ = state.Mode.To(fsm.Tracking, "test")
+			}
+
+			var got []string
+			for _, object := range countedObjects(state) {
+				got = append(got, object.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("countedObjects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessTripwires(t *testing.T) {
+	const frame = 100 * time.Millisecond
+	config := types.DefaultTrackingConfig()
+	config.TripwireMinDistance = 10
+	config.TripwireCooldown = time.Second
+
+	tests := []struct {
+		name    string
+		ys      []int
+		in, out int
+	}{
+		{"walks through", []int{60, 80, 120, 140}, 1, 0},
+		{"stands in the doorway", []int{80, 95, 105, 94, 106, 95, 105, 96}, 0, 0},
+		{"steps back within the cooldown", []int{80, 120, 80, 120, 80}, 1, 0},
+		{"leaves again after the cooldown", []int{80, 120, 120, 120, 120, 120, 120, 120, 120, 120, 120, 80}, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &types.AppState{
+				Tripwires: []tripwire.Line{{Name: "door", Start: image.Pt(0, 100), End: image.Pt(200, 100)}},
+			}
+			_ = state.Mode.To(fsm.Searching, "test")
+			_ = state.Mode.To(fsm.Tracking, "test")
+
+			for i, y := range tt.ys {
+				state.FrameTimestamp = time.Duration(i) * frame
+				state.LastKnownRect = image.Rect(80, y-20, 120, y+20)
+				state.TargetHistory = state.TargetHistory.Add(image.Pt(100, y), state.FrameTimestamp, 10)
+				ProcessTripwires(state, config)
+			}
+
+			if line := state.Tripwires[0]; line.In != tt.in || line.Out != tt.out {
+				t.Errorf("counts = in %d, out %d, want in %d, out %d", line.In, line.Out, tt.in, tt.out)
+			}
+		})
+	}
+}
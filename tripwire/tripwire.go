@@ -0,0 +1,221 @@
+package tripwire
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Direction is the way a line was crossed
+type Direction string
+
+const (
+	// AToB is a crossing from side A to side B of a line, counted as in
+	AToB Direction = "A->B"
+	// BToA is a crossing from side B to side A of a line, counted as out
+	BToA Direction = "B->A"
+)
+
+// Line is a named tripwire segment in working frame coordinates. Side A is
+// on the left when looking from Start to End on screen, side B on the right.
+type Line struct {
+	Name  string      `json:"name"`
+	Start image.Point `json:"start"`
+	End   image.Point `json:"end"`
+
+	// Running counts of crossings from A to B and from B to A
+	In  int `json:"-"`
+	Out int `json:"-"`
+
+	// Where each object was last seen clearly on one side of the line
+	objects map[string]*objectSide
+}
+
+// objectSide is the last position of an object away from a line, and when
+// the object was last counted crossing it
+type objectSide struct {
+	point      image.Point
+	counted    time.Duration
+	hasCounted bool
+}
+
+// Crossing is a line crossed by a tracked object
+type Crossing struct {
+	Line      string
+	Object    string
+	Direction Direction
+}
+
+// side returns -1 for points on side A, 1 for side B and 0 on the line itself
+func side(start, end, p image.Point) int {
+	cross := (end.X-start.X)*(p.Y-start.Y) - (end.Y-start.Y)*(p.X-start.X)
+	switch {
+	case cross < 0:
+		return -1
+	case cross > 0:
+		return 1
+	}
+	return 0
+}
+
+// Cross reports whether a center moving from prev to cur crossed the line
+// segment, and in which direction. Touching the line doesn't count until
+// the center is through it.
+func (l Line) Cross(prev, cur image.Point) (Direction, bool) {
+	from, to := side(l.Start, l.End, prev), side(l.Start, l.End, cur)
+	if from == 0 || to == 0 || from == to {
+		return "", false
+	}
+	// The motion must pass between the ends of the segment
+	if side(prev, cur, l.Start)*side(prev, cur, l.End) > 0 {
+		return "", false
+	}
+	if from < 0 {
+		return AToB, true
+	}
+	return BToA, true
+}
+
+// distance returns the distance of p from the line through the segment,
+// negative on side A and positive on side B
+func (l Line) distance(p image.Point) float64 {
+	cross := (l.End.X-l.Start.X)*(p.Y-l.Start.Y) - (l.End.Y-l.Start.Y)*(p.X-l.Start.X)
+	return float64(cross) / math.Hypot(float64(l.End.X-l.Start.X), float64(l.End.Y-l.Start.Y))
+}
+
+// Track follows the center of an object across the line and reports a
+// crossing once the center is through it. Positions closer than minDistance
+// to the line are ignored, so a center jittering on the line is counted at
+// most once, and a crossing within cooldown of the object's last counted
+// crossing is dropped.
+func (l *Line) Track(object string, center image.Point, now time.Duration, minDistance float64, cooldown time.Duration) (Direction, bool) {
+	if d := math.Abs(l.distance(center)); d == 0 || d < minDistance {
+		return "", false
+	}
+	if l.objects == nil {
+		l.objects = make(map[string]*objectSide)
+	}
+	last, ok := l.objects[object]
+	if !ok {
+		l.objects[object] = &objectSide{point: center}
+		return "", false
+	}
+
+	direction, crossed := l.Cross(last.point, center)
+	last.point = center
+	if !crossed || (last.hasCounted && now-last.counted < cooldown) {
+		return "", false
+	}
+	last.counted, last.hasCounted = now, true
+	return direction, true
+}
+
+// Count adds a crossing to the running counts
+func (l *Line) Count(direction Direction) {
+	if direction == AToB {
+		l.In++
+	} else {
+		l.Out++
+	}
+}
+
+// Load reads tripwire lines from a JSON file. A missing file means no lines.
+func Load(path string) ([]Line, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read tripwires file: %v", err)
+	}
+
+	var lines []Line
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return nil, fmt.Errorf("could not parse tripwires file %s: %v", path, err)
+	}
+	names := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		if line.Start == line.End {
+			return nil, fmt.Errorf("tripwire %q has no length", line.Name)
+		}
+		if names[line.Name] {
+			return nil, fmt.Errorf("tripwire name %q is used more than once", line.Name)
+		}
+		names[line.Name] = true
+	}
+	return lines, nil
+}
+
+// UniqueName returns a name for a new line, such as line-3, that no existing
+// line has
+func UniqueName(lines []Line) string {
+	taken := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		taken[line.Name] = true
+	}
+	for n := len(lines) + 1; ; n++ {
+		if name := fmt.Sprintf("line-%d", n); !taken[name] {
+			return name
+		}
+	}
+}
+
+// Counts are the totals of the tripwire lines at the end of a session
+type Counts struct {
+	Ended time.Time    `json:"ended"`
+	Lines []LineCounts `json:"lines"`
+}
+
+// LineCounts are the in/out totals of one line
+type LineCounts struct {
+	Name string `json:"name"`
+	In   int    `json:"in"`
+	Out  int    `json:"out"`
+}
+
+// CountsPath returns the counts file kept next to a tripwires file, e.g.
+// tripwires.counts.jsonl for tripwires.json
+func CountsPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".counts.jsonl"
+}
+
+// AppendCounts appends the totals of the lines as one JSON line to the counts
+// file, so that the file keeps a record of every session
+func AppendCounts(path string, lines []Line, ended time.Time) error {
+	counts := Counts{Ended: ended, Lines: make([]LineCounts, len(lines))}
+	for i, line := range lines {
+		counts.Lines[i] = LineCounts{Name: line.Name, In: line.In, Out: line.Out}
+	}
+	data, err := json.Marshal(counts)
+	if err != nil {
+		return fmt.Errorf("could not encode tripwire counts: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open tripwire counts file: %v", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("could not write tripwire counts: %v", err)
+	}
+	return f.Close()
+}
+
+// Save writes tripwire lines to a JSON file
+func Save(path string, lines []Line) error {
+	data, err := json.MarshalIndent(lines, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode tripwires: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("could not write tripwires file: %v", err)
+	}
+	return nil
+}
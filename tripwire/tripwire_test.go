@@ -0,0 +1,166 @@
+package tripwire
+
+import (
+	"encoding/json"
+	"image"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCross(t *testing.T) {
+	// A horizontal doorway from left to right, side A above it and side B below
+	door := Line{Name: "door", Start: image.Pt(0, 100), End: image.Pt(200, 100)}
+
+	tests := []struct {
+		name      string
+		prev, cur image.Point
+		want      Direction
+		crossed   bool
+	}{
+		{"down through the middle", image.Pt(100, 90), image.Pt(100, 110), AToB, true},
+		{"up through the middle", image.Pt(100, 110), image.Pt(100, 90), BToA, true},
+		{"diagonal through", image.Pt(50, 80), image.Pt(150, 120), AToB, true},
+		{"stays above", image.Pt(100, 80), image.Pt(120, 90), "", false},
+		{"along the line", image.Pt(50, 100), image.Pt(150, 100), "", false},
+		{"onto the line", image.Pt(100, 90), image.Pt(100, 100), "", false},
+		{"off the line", image.Pt(100, 100), image.Pt(100, 110), "", false},
+		{"past the end", image.Pt(250, 90), image.Pt(250, 110), "", false},
+		{"past the start", image.Pt(-10, 90), image.Pt(-10, 110), "", false},
+		{"through the end point", image.Pt(200, 90), image.Pt(200, 110), AToB, true},
+		{"no motion", image.Pt(100, 90), image.Pt(100, 90), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, crossed := door.Cross(tt.prev, tt.cur)
+			if got != tt.want || crossed != tt.crossed {
+				t.Errorf("Cross(%v, %v) = %q, %v, want %q, %v", tt.prev, tt.cur, got, crossed, tt.want, tt.crossed)
+			}
+		})
+	}
+}
+
+func TestTrack(t *testing.T) {
+	// The same doorway as in TestCross, centers given as y positions at x 100
+	const frame = 100 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		ys      []int
+		in, out int
+	}{
+		{"walks through", []int{60, 80, 95, 105, 120, 140}, 1, 0},
+		{"walks back", []int{140, 120, 90, 60}, 0, 1},
+		{"jitters on the line", []int{80, 95, 105, 96, 104, 95, 106, 97}, 0, 0},
+		{"jitters after crossing", []int{80, 120, 105, 96, 104, 95, 106}, 1, 0},
+		{"turns back within the cooldown", []int{80, 120, 80, 120}, 1, 0},
+		{"turns back after the cooldown", []int{80, 120, 120, 120, 120, 120, 120, 120, 120, 120, 120, 80}, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			door := Line{Name: "door", Start: image.Pt(0, 100), End: image.Pt(200, 100)}
+			for i, y := range tt.ys {
+				if direction, crossed := door.Track("target", image.Pt(100, y), time.Duration(i)*frame, 10, time.Second); crossed {
+					door.Count(direction)
+				}
+			}
+			if door.In != tt.in || door.Out != tt.out {
+				t.Errorf("counts = in %d, out %d, want in %d, out %d", door.In, door.Out, tt.in, tt.out)
+			}
+		})
+	}
+}
+
+func TestTrackKeepsObjectsApart(t *testing.T) {
+	door := Line{Name: "door", Start: image.Pt(0, 100), End: image.Pt(200, 100)}
+	door.Track("track 1", image.Pt(50, 80), 0, 10, time.Second)
+	door.Track("track 2", image.Pt(150, 120), 0, 10, time.Second)
+
+	// Each object is compared with its own last position, not the other's
+	if _, crossed := door.Track("track 1", image.Pt(50, 70), time.Second, 10, time.Second); crossed {
+		t.Error("track 1 counted although it stayed above the line")
+	}
+	if direction, crossed := door.Track("track 2", image.Pt(150, 80), time.Second, 10, time.Second); !crossed || direction != BToA {
+		t.Errorf("track 2 crossing = %q, %v, want %q, true", direction, crossed, BToA)
+	}
+}
+
+func TestCount(t *testing.T) {
+	var door Line
+	for _, direction := range []Direction{AToB, AToB, BToA, AToB} {
+		door.Count(direction)
+	}
+	if door.In != 3 || door.Out != 1 {
+		t.Errorf("counts = in %d, out %d, want in 3, out 1", door.In, door.Out)
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []Line
+		want  string
+	}{
+		{"first", nil, "line-1"},
+		{"next", []Line{{Name: "line-1"}}, "line-2"},
+		{"after a deleted line", []Line{{Name: "line-2"}}, "line-3"},
+		{"skips taken names", []Line{{Name: "line-2"}, {Name: "line-3"}}, "line-4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UniqueName(tt.lines); got != tt.want {
+				t.Errorf("UniqueName() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsDuplicateNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tripwires.json")
+	lines := []Line{
+		{Name: "door", Start: image.Pt(0, 0), End: image.Pt(10, 0)},
+		{Name: "door", Start: image.Pt(0, 10), End: image.Pt(10, 10)},
+	}
+	if err := Save(path, lines); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load() accepted two lines named door")
+	}
+}
+
+func TestAppendCounts(t *testing.T) {
+	path := CountsPath(filepath.Join(t.TempDir(), "tripwires.json"))
+	if filepath.Base(path) != "tripwires.counts.jsonl" {
+		t.Fatalf("CountsPath = %s, want tripwires.counts.jsonl", path)
+	}
+
+	door := Line{Name: "door", In: 3, Out: 1}
+	for session := 0; session < 2; session++ {
+		if err := AppendCounts(path, []Line{door}, time.Unix(0, 0)); err != nil {
+			t.Fatalf("AppendCounts: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("counts file has %d sessions, want 2", len(lines))
+	}
+	var counts Counts
+	if err := json.Unmarshal([]byte(lines[1]), &counts); err != nil {
+		t.Fatal(err)
+	}
+	if want := []LineCounts{{Name: "door", In: 3, Out: 1}}; !reflect.DeepEqual(counts.Lines, want) {
+		t.Errorf("counts = %+v, want %+v", counts.Lines, want)
+	}
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"fmt"
+	"math"
+)
+
+// compassPoints names headings in 45° steps, starting with up in the image
+var compassPoints = []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// Speed is the smoothed velocity of a target in working frame pixels per second
+type Speed struct {
+	VX    float64
+	VY    float64
+	Valid bool
+
+	// Exceeded is set while the speed is above the configured limit
+	Exceeded bool
+}
+
+// Update blends a new velocity measurement into the speed with exponential
+// smoothing; a smoothing of 0 takes the measurement as is
+func (s *Speed) Update(vx, vy, smoothing float64) {
+	if !s.Valid {
+		s.VX, s.VY, s.Valid = vx, vy, true
+		return
+	}
+	s.VX = smoothing*s.VX + (1-smoothing)*vx
+	s.VY = smoothing*s.VY + (1-smoothing)*vy
+}
+
+// PixelsPerSecond returns the magnitude of the speed
+func (s Speed) PixelsPerSecond() float64 {
+	return math.Hypot(s.VX, s.VY)
+}
+
+// Heading returns the direction of motion in degrees clockwise from up in the image
+func (s Speed) Heading() float64 {
+	heading := math.Atan2(s.VX, -s.VY) * 180 / math.Pi
+	if heading < 0 {
+		heading += 360
+	}
+	return heading
+}
+
+// Compass returns the heading as a compass point, with up in the image as north
+func (s Speed) Compass() string {
+	return compassPoints[int(math.Round(s.Heading()/45))%len(compassPoints)]
+}
+
+// Format describes the speed in m/s when a scale is known, otherwise in px/s
+func (s Speed) Format(metersPerPixel float64) string {
+	if metersPerPixel > 0 {
+		return fmt.Sprintf("%.1f m/s %s", s.PixelsPerSecond()*metersPerPixel, s.Compass())
+	}
+	return fmt.Sprintf("%.0f px/s %s", s.PixelsPerSecond(), s.Compass())
+}
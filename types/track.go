@@ -0,0 +1,72 @@
+package types
+
+import (
+	"image"
+
+	"tracker/motion"
+)
+
+// TrackState is the lifecycle stage of a track
+type TrackState int
+
+const (
+	// TrackTentative is a new track that hasn't been matched often enough to be trusted
+	TrackTentative TrackState = iota
+	// TrackConfirmed is a track that is matched consistently
+	TrackConfirmed
+	// TrackLost is a confirmed track that recently went unmatched
+	TrackLost
+	// TrackDeleted is a track that is about to be removed
+	TrackDeleted
+)
+
+// String returns the name of the track state
+func (s TrackState) String() string {
+	switch s {
+	case TrackTentative:
+		return "tentative"
+	case TrackConfirmed:
+		return "confirmed"
+	case TrackLost:
+		return "lost"
+	case TrackDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// Track is a single object followed by the multi-object tracker
+type Track struct {
+	ID    int
+	Rect  image.Rectangle
+	State TrackState
+
+	// Hits counts consecutive frames with a matching detection
+	Hits int
+	// Misses counts consecutive frames without a matching detection
+	Misses int
+	// Age counts frames since the track was created
+	Age int
+
+	// Filter predicts the track's motion between detections
+	Filter *motion.Kalman
+
+	// History holds the recent matched centers of the track
+	History Trajectory
+	// Speed is the smoothed velocity estimated from the history
+	Speed Speed
+}
+
+// Center returns the center point of the track's bounding box
+func (t *Track) Center() image.Point {
+	return image.Pt(t.Rect.Min.X+t.Rect.Dx()/2, t.Rect.Min.Y+t.Rect.Dy()/2)
+}
+
+// Warp moves the track along with the image, e.g. to follow camera motion
+func (t *Track) Warp(m motion.Affine) {
+	t.Rect = m.Rect(t.Rect)
+	if t.Filter != nil {
+		t.Filter.Warp(m)
+	}
+	t.History.Warp(m)
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"image"
+	"time"
+
+	"tracker/motion"
+)
+
+// TrajectoryPoint is a target center at a point in time
+type TrajectoryPoint struct {
+	Center image.Point
+	Time   time.Duration
+}
+
+// Trajectory is a bounded history of target centers, oldest first
+type Trajectory []TrajectoryPoint
+
+// Add appends a center and drops the oldest points beyond limit
+func (t Trajectory) Add(center image.Point, at time.Duration, limit int) Trajectory {
+	return append(t, TrajectoryPoint{Center: center, Time: at}).Trim(limit)
+}
+
+// Trim drops the oldest points beyond limit
+func (t Trajectory) Trim(limit int) Trajectory {
+	if limit > 0 && len(t) > limit {
+		// Copy down instead of reslicing so the backing array doesn't grow forever
+		n := copy(t, t[len(t)-limit:])
+		t = t[:n]
+	}
+	return t
+}
+
+// Velocity returns the average velocity in pixels per second over the points
+// of the last window of time. It reports false while the points span no time.
+func (t Trajectory) Velocity(window time.Duration) (float64, float64, bool) {
+	if len(t) < 2 {
+		return 0, 0, false
+	}
+
+	last := t[len(t)-1]
+	first := len(t) - 2
+	for first > 0 && last.Time-t[first-1].Time <= window {
+		first--
+	}
+
+	dt := (last.Time - t[first].Time).Seconds()
+	if dt <= 0 {
+		return 0, 0, false
+	}
+	return float64(last.Center.X-t[first].Center.X) / dt, float64(last.Center.Y-t[first].Center.Y) / dt, true
+}
+
+// Warp moves the points in place along with the image, e.g. to follow camera motion
+func (t Trajectory) Warp(m motion.Affine) {
+	for i := range t {
+		t[i].Center = m.Apply(t[i].Center)
+	}
+}
@@ -9,39 +9,128 @@ import (
 	"time"
 
 	"gocv.io/x/gocv"
+
+	"tracker/fsm"
+	"tracker/motion"
+	"tracker/reid"
+	"tracker/tripwire"
+	"tracker/utils"
+	"tracker/zones"
 )
 
 // AppState holds the complete application state
 type AppState struct {
 	// Tracking state
-	Tracker             gocv.Tracker
-	TrackingEnabled     bool
-	AutoTrackingEnabled bool
-	ROI                 image.Rectangle
-	InitialROISize      int
+	Mode        fsm.Machine
+	Tracker     gocv.Tracker
+	TrackerName string
+	ROI         image.Rectangle
+
+	// Slowly adapting expected size of the target box, starting at the ROI size
+	TargetSize utils.SizeReference
+
+	// Class of the target when it was picked by an object detector
+	TargetLabel string
+
+	// Auto-tracking target selection, candidates are followed across frames while searching
+	TargetPolicy    string
+	Candidates      []*Track
+	NextCandidateID int
 
 	// Tracking robustness
 	TrackingFailureCount int
 	LastKnownRect        image.Rectangle
+	TargetConfidence     float64
+
+	// Motion prediction for the tracked target
+	TargetFilter  *motion.Kalman
+	PredictedRect image.Rectangle
+
+	// Recent confirmed centers of the tracked target and of every track
+	TargetHistory    Trajectory
+	TrajectoryLength int
+	ShowTrajectories bool
+
+	// Smoothed speed of the tracked target and the pixel-to-world scale (0 if unknown)
+	TargetSpeed    Speed
+	MetersPerPixel float64
+
+	// Scale measurement in the UI, two clicked points a reference length apart
+	Measuring     bool
+	MeasurePoints []image.Point
+
+	// Appearance of the current or most recently lost target, used for re-identification
+	TargetAppearance *reid.Model
+
+	// Grayscale patch of the last confident view of the target, used for recovery
+	TargetPatch gocv.Mat
 
 	// ROI selection
-	ROISelectionMode bool
-	ROICenterX       int
-	ROICenterY       int
-	ROIWidth         int
-	ROIHeight        int
+	ROICenterX int
+	ROICenterY int
+	ROIWidth   int
+	ROIHeight  int
 
 	// Video recording
 	IsRecording        bool
 	VideoWriter        *gocv.VideoWriter
 	RecordingStartTime time.Time
 
-	// Background subtraction
-	BackSub gocv.BackgroundSubtractorMOG2
-	FgMask  gocv.Mat
+	// Object detector for auto-tracking, nil when only motion is used
+	Detector ObjectDetector
+
+	// Background subtraction, run on the frame downscaled to the detection
+	// scale into DetectionFrame. FgMask stays at the detection scale.
+	BackSub           BackgroundSubtractor
+	DetectionFrame    gocv.Mat
+	FgMask            gocv.Mat
+	ForegroundUpdated bool
+
+	// Camera motion compensation, nil when the camera is static. FrameMotion
+	// maps the previous frame to the current one, BackgroundAlignment the
+	// current frame to the coordinates the background model was learned in.
+	// TrackerShiftX/Y add up how far the camera has moved the target since
+	// its tracker was last started.
+	CameraMotion        CameraMotionEstimator
+	FrameMotion         motion.Affine
+	BackgroundAlignment motion.Affine
+	TrackerShiftX       float64
+	TrackerShiftY       float64
+
+	// Detection zones and the zone being drawn in the UI
+	Zones         []zones.Zone
+	ZoneDrawing   bool
+	ZoneDraft     []image.Point
+	ZoneDraftKind zones.Kind
+
+	// Dwell times in the watch zones and the zone events of the current frame
+	Dwell      *zones.Dwell
+	ZoneEvents []zones.Event
+
+	// Tripwire lines with their counts, the crossings of the current frame
+	// and the line being drawn in the UI
+	Tripwires   []tripwire.Line
+	Crossings   []tripwire.Crossing
+	LineDrawing bool
+	LineDraft   []image.Point
+
+	// Multi-object tracking
+	MultiTrackingEnabled bool
+	Tracks               []*Track
+	NextTrackID          int
 
 	// Frame processing
-	FrameCount int
+	FrameCount     int
+	FrameTimestamp time.Duration
+
+	// Source frame identity (image sequences)
+	SourceFrameIndex int
+	SourceFrameName  string
+	SourceFrameSize  image.Point
+
+	// Source connection
+	SourceReconnecting bool
+	DroppedFrames      uint64
 
 	// Debug logging
 	DebugMode    bool
@@ -49,46 +138,308 @@ type AppState struct {
 	DebugLogMutex sync.Mutex
 }
 
+// BackgroundSubtractor separates moving foreground from the static background
+type BackgroundSubtractor interface {
+	Apply(src gocv.Mat, dst *gocv.Mat) error
+	Close() error
+}
+
+// CameraMotionEstimator estimates the global motion between consecutive frames
+type CameraMotionEstimator interface {
+	Estimate(frame gocv.Mat) (motion.Affine, bool)
+	Close() error
+}
+
+// DetectedObject is an object of a known class found by an ObjectDetector
+type DetectedObject struct {
+	Rect  image.Rectangle
+	Label string
+	Score float64
+}
+
+// ObjectDetector finds objects of known classes in a frame
+type ObjectDetector interface {
+	Detect(frame gocv.Mat) ([]DetectedObject, error)
+	Close() error
+}
+
 // TrackingConfig holds tracking configuration constants
 type TrackingConfig struct {
+	TrackerAlgorithm    string
+	TargetPolicy        string
 	MaxROIGrowth        float64
+	MaxSizeStep         float64
+	SizeAdaptRate       float64
 	MinROISize          int
 	MaxTrackingFailures int
 	SearchRadius        int
 	SizeChangeThreshold float64
 	MinContourArea      float64
 	StabilizationFrames int
+
+	// Constant-velocity motion model
+	KalmanAccelNoise       float64
+	KalmanMeasurementNoise float64
+
+	// Minimum appearance similarity (0-1) to re-identify a lost target, 0 disables
+	ReIDThreshold float64
+
+	// Tracker updates scoring below MinConfidence (0-1) count as failures
+	MinConfidence float64
+
+	// Recovery searches for the last good target patch at these scales and
+	// re-initializes only on a match scoring at least RecoveryMatchThreshold (0-1)
+	RecoveryScales         []float64
+	RecoveryMatchThreshold float64
+
+	// Multi-object track association and lifecycle
+	MinTrackIoU      float64
+	TrackConfirmHits int
+	TrackMaxMisses   int
+
+	// Frames a candidate must be seen in a row before the persistent policy selects it
+	PolicyPersistenceFrames int
+
+	// Background subtraction: MOG2 or KNN. The variance threshold applies to
+	// MOG2 and the squared distance threshold to KNN. A negative learning rate
+	// lets the model choose it from the history length (MOG2 only).
+	BackgroundSubtractor     string
+	BackgroundHistory        int
+	BackgroundVarThreshold   float64
+	BackgroundDist2Threshold float64
+	BackgroundDetectShadows  bool
+	BackgroundLearningRate   float64
+
+	// Foreground mask cleanup, each step is off at 0. Shadow threshold drops
+	// shadow pixels, sizes are kernel sizes in pixels.
+	MaskShadowThreshold  float64
+	MaskErodeIterations  int
+	MaskDilateIterations int
+	MaskOpenSize         int
+	MaskCloseSize        int
+	MaskMedianSize       int
+
+	// File the include/exclude detection zones are loaded from and saved to
+	ZonesFile string
+
+	// How long an object may stay in a watch zone before it is loitering (0 disables alerts)
+	LoiterTime time.Duration
+
+	// File the tripwire lines are loaded from and saved to, how far in px a
+	// center must get past a line before it is on the other side, and how
+	// long a crossing by the same object is ignored after one was counted
+	TripwiresFile       string
+	TripwireMinDistance float64
+	TripwireCooldown    time.Duration
+
+	// Object detector for auto-tracking ("" for motion only, "dnn", "cascade"). It replaces
+	// motion detection unless DetectorCombine is set and runs every
+	// DetectorInterval frames. DetectorClasses is an allow-list of class names.
+	Detector            string
+	DetectorCombine     bool
+	DetectorInterval    int
+	DetectorModel       string
+	DetectorClassesFile string
+	DetectorClasses     []string
+	DetectorInputSize   int
+	DetectorConfidence  float64
+	DetectorNMS         float64
+
+	// Haar or LBP cascade for the cascade detector, detections are at least MinROISize
+	CascadeFile         string
+	CascadeScaleFactor  float64
+	CascadeMinNeighbors int
+
+	// Number of recent centers kept per target for trajectories
+	TrajectoryLength int
+
+	// Speed is averaged over SpeedWindow of the trajectory and smoothed with
+	// SpeedSmoothing (0-1, higher is smoother). MetersPerPixel is the initial
+	// scale, ReferenceLength the length in meters measured in the UI. A target
+	// faster than SpeedLimit (m/s, 0 disables) raises an alert.
+	SpeedWindow     time.Duration
+	SpeedSmoothing  float64
+	MetersPerPixel  float64
+	ReferenceLength float64
+	SpeedLimit      float64
+
+	// Camera motion compensation for moving cameras: ORB features per frame,
+	// the fewest RANSAC inliers to trust a motion estimate, the camera shift
+	// of the target in px, added up over frames, above which its tracker is
+	// moved along, and the fraction of the frame still covered by the
+	// background model below which the model is restarted
+	CameraMotion              bool
+	CameraMotionFeatures      int
+	CameraMotionMinInliers    int
+	CameraMotionReinitShift   float64
+	CameraMotionResetCoverage float64
+
+	// Resolution of the detection stage (background subtraction, object
+	// detection, camera motion) and of the tracking stage relative to the
+	// working frame, e.g. 0.25 and 0.5. Results are mapped back to it, the
+	// foreground mask is kept at the detection scale.
+	DetectionScale float64
+	TrackingScale  float64
 }
 
 // DefaultTrackingConfig returns the default tracking configuration
 func DefaultTrackingConfig() TrackingConfig {
 	return TrackingConfig{
+		TrackerAlgorithm:    "CSRT",
+		TargetPolicy:        "largest",
 		MaxROIGrowth:        2.0,
+		MaxSizeStep:         0.1,
+		SizeAdaptRate:       0.02,
 		MinROISize:          40,
 		MaxTrackingFailures: 12,
 		SearchRadius:        120,
 		SizeChangeThreshold: 5.0,
 		MinContourArea:      500,
 		StabilizationFrames: 30,
+
+		KalmanAccelNoise:       200,
+		KalmanMeasurementNoise: 5,
+
+		ReIDThreshold: 0.6,
+
+		MinConfidence: 0.25,
+
+		RecoveryScales:         []float64{0.8, 0.9, 1.0, 1.1, 1.25},
+		RecoveryMatchThreshold: 0.6,
+
+		MinTrackIoU:      0.2,
+		TrackConfirmHits: 3,
+		TrackMaxMisses:   15,
+
+		PolicyPersistenceFrames: 15,
+
+		BackgroundSubtractor:     "MOG2",
+		BackgroundHistory:        500,
+		BackgroundVarThreshold:   16,
+		BackgroundDist2Threshold: 400,
+		BackgroundDetectShadows:  true,
+		BackgroundLearningRate:   -1,
+
+		ZonesFile:           "zones.json",
+		TripwiresFile:       "tripwires.json",
+		TripwireMinDistance: 10,
+		TripwireCooldown:    time.Second,
+		LoiterTime:          30 * time.Second,
+
+		DetectorInterval:   5,
+		DetectorInputSize:  640,
+		DetectorConfidence: 0.5,
+		DetectorNMS:        0.45,
+
+		CascadeScaleFactor:  1.1,
+		CascadeMinNeighbors: 4,
+
+		TrajectoryLength: 64,
+
+		SpeedWindow:     500 * time.Millisecond,
+		SpeedSmoothing:  0.7,
+		ReferenceLength: 1.0,
+
+		CameraMotionFeatures:      500,
+		CameraMotionMinInliers:    15,
+		CameraMotionReinitShift:   24.0,
+		CameraMotionResetCoverage: 0.5,
+
+		DetectionScale: 1,
+		TrackingScale:  1,
 	}
 }
 
 // VideoConfig holds video recording configuration
 type VideoConfig struct {
-	FPS    float64
-	Codecs []string
+	FPS           float64
+	Codecs        []string
+	RecordOnStart bool
 }
 
 // DefaultVideoConfig returns the default video configuration
 func DefaultVideoConfig() VideoConfig {
 	return VideoConfig{
-		FPS:    30.0,
-		Codecs: []string{"H264", "avc1", "x264", "mp4v"},
+		FPS:           30.0,
+		Codecs:        []string{"H264", "avc1", "x264", "mp4v"},
+		RecordOnStart: false,
+	}
+}
+
+// PreprocessConfig holds the frame preprocessing applied before tracking
+type PreprocessConfig struct {
+	Mirror bool
+	// Rotate is a clockwise rotation in degrees: 0, 90, 180 or 270
+	Rotate int
+	// Crop is applied after mirroring and rotation, an empty rectangle keeps the whole frame
+	Crop image.Rectangle
+	// ResizeWidth and ResizeHeight set the working resolution, 0 keeps the aspect ratio
+	ResizeWidth  int
+	ResizeHeight int
+}
+
+// DefaultPreprocessConfig returns the default preprocessing configuration
+func DefaultPreprocessConfig() PreprocessConfig {
+	return PreprocessConfig{
+		Mirror: true,
+		Rotate: 0,
+	}
+}
+
+// RunConfig holds settings for how the main loop is driven
+type RunConfig struct {
+	Headless    bool
+	HeadlessFPS float64
+}
+
+// DefaultRunConfig returns the default run configuration
+func DefaultRunConfig() RunConfig {
+	return RunConfig{
+		Headless:    false,
+		HeadlessFPS: 0,
+	}
+}
+
+// SourceConfig holds frame source configuration
+type SourceConfig struct {
+	Source string
+	Loop   bool
+
+	// Image sequence playback
+	FPS        float64
+	StartIndex int
+	EndIndex   int
+
+	// CaptureBuffer is the number of frames queued between capture and
+	// tracking for live sources, 0 captures on the main loop
+	CaptureBuffer int
+
+	// Network stream reconnection backoff
+	ReconnectDelay    time.Duration
+	ReconnectMaxDelay time.Duration
+}
+
+// DefaultSourceConfig returns the default frame source configuration
+func DefaultSourceConfig() SourceConfig {
+	return SourceConfig{
+		Source:     "0",
+		Loop:       false,
+		FPS:        30.0,
+		StartIndex: 0,
+		EndIndex:   -1,
+
+		CaptureBuffer: 1,
+
+		ReconnectDelay:    500 * time.Millisecond,
+		ReconnectMaxDelay: 10 * time.Second,
 	}
 }
 
 // UIConfig holds UI configuration constants
 type UIConfig struct {
+	// ConfidenceWarning is the tracking confidence below which the box is drawn as uncertain
+	ConfidenceWarning float64
+
 	HelpFontSize   float64
 	StatusFontSize float64
 	HelpOffsetY    int
@@ -177,6 +528,8 @@ func (d *DebugLogger) RestoreOriginalLogOutput() {
 // DefaultUIConfig returns the default UI configuration
 func DefaultUIConfig() UIConfig {
 	return UIConfig{
+		ConfidenceWarning: 0.6,
+
 		HelpFontSize:   0.9,
 		StatusFontSize: 1.5,
 		HelpOffsetY:    60,
@@ -5,11 +5,14 @@ import (
 	"image"
 	"image/color"
 	"log"
+	"math"
 
 	"gocv.io/x/gocv"
 
+	"tracker/fsm"
 	"tracker/recording"
 	"tracker/types"
+	"tracker/zones"
 )
 
 var (
@@ -21,18 +24,146 @@ var (
 	Black  = color.RGBA{R: 0, G: 0, B: 0, A: 120}
 )
 
-// DrawTrackingRect draws the tracking rectangle on the frame
-func DrawTrackingRect(frame *gocv.Mat, rect image.Rectangle, success bool) {
+// DrawTrackingRect draws the tracking rectangle on the frame, colored by how
+// much the tracker can be trusted: blue when confident, yellow when uncertain,
+// red when failing. The confidence is printed below it, after the class label if known.
+func DrawTrackingRect(frame *gocv.Mat, rect image.Rectangle, success bool, confidence float64, label string, config types.UIConfig) {
 	rectColor := Blue
-	if !success {
+	switch {
+	case !success:
 		rectColor = Red
+	case confidence < config.ConfidenceWarning:
+		rectColor = Yellow
 	}
 	_ = gocv.Rectangle(frame, rect, rectColor, 3)
+
+	confidenceText := fmt.Sprintf("%.0f%%", confidence*100)
+	if label != "" {
+		confidenceText = fmt.Sprintf("%s %s", label, confidenceText)
+	}
+	if err := gocv.PutText(frame, confidenceText, image.Pt(rect.Min.X, rect.Max.Y+15), gocv.FontHersheyPlain, 1.0, rectColor, 1); err != nil {
+		log.Printf("Error adding confidence text: %v", err)
+	}
+}
+
+// DrawSpeed draws the speed and heading of a target at pos, in red while it
+// is above the speed limit
+func DrawSpeed(frame *gocv.Mat, pos image.Point, speed types.Speed, metersPerPixel float64, textColor color.RGBA) {
+	if !speed.Valid {
+		return
+	}
+	if speed.Exceeded {
+		textColor = Red
+	}
+	if err := gocv.PutText(frame, speed.Format(metersPerPixel), pos, gocv.FontHersheyPlain, 1.0, textColor, 1); err != nil {
+		log.Printf("Error adding speed text: %v", err)
+	}
+}
+
+// trackPalette holds the colors tracks are drawn with, picked by track ID
+var trackPalette = []color.RGBA{
+	{R: 230, G: 25, B: 75},
+	{R: 60, G: 180, B: 75},
+	{R: 255, G: 225, B: 25},
+	{R: 0, G: 130, B: 200},
+	{R: 245, G: 130, B: 48},
+	{R: 145, G: 30, B: 180},
+	{R: 70, G: 240, B: 240},
+	{R: 240, G: 50, B: 230},
+	{R: 210, G: 245, B: 60},
+	{R: 250, G: 190, B: 212},
+}
+
+// TrackColor returns the display color of a track
+func TrackColor(id int) color.RGBA {
+	return trackPalette[id%len(trackPalette)]
+}
+
+// DrawTracks draws every live multi-object track with its ID in its own color
+func DrawTracks(frame *gocv.Mat, state *types.AppState) {
+	if !state.MultiTrackingEnabled {
+		return
+	}
+
+	for _, track := range state.Tracks {
+		if track.State == types.TrackDeleted {
+			continue
+		}
+
+		trackColor := TrackColor(track.ID)
+		thickness := 2
+		if track.State != types.TrackConfirmed {
+			// Tentative and lost tracks are drawn thin
+			thickness = 1
+		}
+		_ = gocv.Rectangle(frame, track.Rect, trackColor, thickness)
+
+		label := fmt.Sprintf("#%d", track.ID)
+		if track.State == types.TrackLost {
+			label += " lost"
+		}
+		labelPos := image.Pt(track.Rect.Min.X, track.Rect.Min.Y-5)
+		if err := gocv.PutText(frame, label, labelPos, gocv.FontHersheyPlain, 1.2, trackColor, 2); err != nil {
+			log.Printf("Error adding track label: %v", err)
+		}
+		DrawSpeed(frame, image.Pt(track.Rect.Min.X, track.Rect.Max.Y+15), track.Speed, state.MetersPerPixel, trackColor)
+	}
+}
+
+// DrawTrajectory draws a trajectory as a polyline that fades out towards its oldest points
+func DrawTrajectory(frame *gocv.Mat, trajectory types.Trajectory, trailColor color.RGBA) {
+	for i := 1; i < len(trajectory); i++ {
+		// Newer segments are brighter and thicker
+		age := float64(i) / float64(len(trajectory)-1)
+		fade := 0.15 + 0.85*age
+		segmentColor := color.RGBA{
+			R: uint8(float64(trailColor.R) * fade),
+			G: uint8(float64(trailColor.G) * fade),
+			B: uint8(float64(trailColor.B) * fade),
+		}
+		_ = gocv.Line(frame, trajectory[i-1].Center, trajectory[i].Center, segmentColor, 1+int(2*age))
+	}
+}
+
+// DrawTrajectories draws the trail of the tracked target and of every live track
+func DrawTrajectories(frame *gocv.Mat, state *types.AppState) {
+	if !state.ShowTrajectories {
+		return
+	}
+
+	DrawTrajectory(frame, state.TargetHistory, Blue)
+	if !state.MultiTrackingEnabled {
+		return
+	}
+	for _, track := range state.Tracks {
+		if track.State != types.TrackDeleted {
+			DrawTrajectory(frame, track.History, TrackColor(track.ID))
+		}
+	}
+}
+
+// DrawMotionEstimate draws the motion model of the tracked target in debug mode:
+// an arrow to where it will be in half a second and a circle showing the position uncertainty
+func DrawMotionEstimate(frame *gocv.Mat, state *types.AppState) {
+	if !state.DebugMode || !state.Mode.Current().HasTarget() || state.TargetFilter == nil {
+		return
+	}
+
+	pos := state.TargetFilter.Position()
+	vx, vy := state.TargetFilter.Velocity()
+	ahead := image.Pt(pos.X+int(vx*0.5), pos.Y+int(vy*0.5))
+	_ = gocv.ArrowedLine(frame, pos, ahead, Yellow, 2)
+	_ = gocv.Circle(frame, pos, int(state.TargetFilter.PositionStd())+1, Yellow, 1)
+
+	velocityText := fmt.Sprintf("v=(%.0f,%.0f) px/s", vx, vy)
+	if err := gocv.PutText(frame, velocityText, image.Pt(pos.X+10, pos.Y-10), gocv.FontHersheyPlain, 1.0, Yellow, 1); err != nil {
+		log.Printf("Error adding velocity text: %v", err)
+	}
 }
 
 // DrawROISelection draws the ROI selection rectangle and crosshair
 func DrawROISelection(frame *gocv.Mat, state *types.AppState) {
-	if !state.ROISelectionMode {
+	if !state.Mode.Is(fsm.Selecting) {
 		return
 	}
 
@@ -66,22 +197,49 @@ func DrawROISelection(frame *gocv.Mat, state *types.AppState) {
 	_ = gocv.Line(frame, image.Pt(state.ROICenterX, state.ROICenterY-10), image.Pt(state.ROICenterX, state.ROICenterY+10), Yellow, 1)
 }
 
+// DrawMeasurement draws the clicked end of the reference while the scale is measured
+func DrawMeasurement(frame *gocv.Mat, state *types.AppState) {
+	if !state.Measuring {
+		return
+	}
+	for _, p := range state.MeasurePoints {
+		_ = gocv.Circle(frame, p, 4, Yellow, -1)
+	}
+}
+
 // DrawStatusMessage draws the main status message
 func DrawStatusMessage(frame *gocv.Mat, state *types.AppState, config types.UIConfig) {
 	var statusText string
 	var textColor color.RGBA
 
 	switch {
-	case state.ROISelectionMode:
+	case state.SourceReconnecting:
+		statusText = "Source lost - reconnecting..."
+		textColor = Yellow
+	case state.Measuring:
+		statusText = "Measuring scale: click both ends of the reference, ESC: cancel"
+		textColor = Yellow
+	case state.LineDrawing:
+		statusText = "Drawing tripwire: click the start and end of the line, ESC: cancel"
+		textColor = Yellow
+	case state.ZoneDrawing:
+		statusText = fmt.Sprintf("Drawing %s zone: click to add points, ENTER: save, ESC: cancel", state.ZoneDraftKind)
+		textColor = Yellow
+	case state.Mode.Is(fsm.Selecting):
 		statusText = "Arrow keys/WASD: move, +/-: resize, ENTER: confirm, ESC: cancel"
 		textColor = Yellow
-	case !state.TrackingEnabled:
-		if state.AutoTrackingEnabled {
-			statusText = "Auto-tracking: Looking for objects..."
-		} else {
-			statusText = "Press 's' for manual ROI or 'a' for auto"
-		}
+	case state.Mode.Is(fsm.Searching):
+		statusText = "Auto-tracking: Looking for objects..."
+		textColor = Red
+	case state.Mode.Is(fsm.Lost):
+		statusText = "Target lost: Looking for it again..."
+		textColor = Red
+	case state.Mode.Is(fsm.Idle):
+		statusText = "Press 's' for manual ROI or 'a' for auto"
 		textColor = Red
+	case state.Mode.Is(fsm.Occluded):
+		statusText = "Target occluded - recovering..."
+		textColor = Yellow
 	default:
 		statusText = "Tracking active"
 		textColor = Green
@@ -106,15 +264,56 @@ func DrawRecordingStatus(frame *gocv.Mat, state *types.AppState, config types.UI
 	}
 }
 
+// DrawHUD draws the active settings in the top right corner
+func DrawHUD(frame *gocv.Mat, state *types.AppState, config types.UIConfig) {
+	lines := []string{
+		fmt.Sprintf("Tracker: %s", state.TrackerName),
+		fmt.Sprintf("Policy: %s", state.TargetPolicy),
+		trajectoryStatus(state),
+		scaleStatus(state),
+		fmt.Sprintf("State: %s", state.Mode.Current()),
+	}
+
+	for i, line := range lines {
+		textSize := gocv.GetTextSize(line, gocv.FontHersheyPlain, config.HelpFontSize, 1)
+		pos := image.Pt(frame.Cols()-textSize.X-10, 20+i*(textSize.Y+8))
+		if err := gocv.PutText(frame, line, pos, gocv.FontHersheyPlain, config.HelpFontSize, White, 1); err != nil {
+			log.Printf("Error adding HUD text: %v", err)
+		}
+	}
+}
+
+// trajectoryStatus describes the trail display for the HUD
+func trajectoryStatus(state *types.AppState) string {
+	if !state.ShowTrajectories {
+		return "Trails: off"
+	}
+	return fmt.Sprintf("Trails: %d", state.TrajectoryLength)
+}
+
+// scaleStatus describes the pixel-to-world scale for the HUD
+func scaleStatus(state *types.AppState) string {
+	if state.MetersPerPixel <= 0 {
+		return "Scale: none"
+	}
+	return fmt.Sprintf("Scale: %.4f m/px", state.MetersPerPixel)
+}
+
 // DrawHelpText draws the compact help text in the bottom corner
 func DrawHelpText(frame *gocv.Mat, state *types.AppState, config types.UIConfig) {
 	helpY := frame.Rows() - config.HelpOffsetY
 
 	var helpText string
-	if state.ROISelectionMode {
+	if state.Measuring {
+		helpText = "Scale: Click=reference end  Esc=cancel"
+	} else if state.LineDrawing {
+		helpText = "Tripwire: Click=line end  Esc=cancel"
+	} else if state.ZoneDrawing {
+		helpText = "Zone: Click=add point  i=include  e=exclude  w=watch  Backspace=undo  Enter=save  Esc=cancel"
+	} else if state.Mode.Is(fsm.Selecting) {
 		helpText = "ROI: Arrows=move  +/-=resize  Enter=confirm  Esc=cancel"
 	} else {
-		helpText = "Controls: s=ROI  a=auto  r=reset  v=record  d=debug  q=quit"
+		helpText = "Controls: s=ROI  a=auto  p=policy  m=multi  t=tracker  h/[/]=trail  z/x=zone  l/L=line  k=scale  r=reset  v=record  d=debug  q=quit"
 	}
 
 	// Small background for readability
@@ -161,7 +360,7 @@ func DrawDebugLogs(frame *gocv.Mat, state *types.AppState, config types.UIConfig
 	}
 
 	// Draw debug header
-	headerText := fmt.Sprintf("Debug Logs (%d):", len(logs))
+	headerText := fmt.Sprintf("Debug Logs (%d) | dropped frames: %d", len(logs), state.DroppedFrames)
 	if err := gocv.PutText(frame, headerText, image.Pt(frameWidth-maxWidth, startY), gocv.FontHersheyPlain, config.DebugFontSize, Yellow, 1); err != nil {
 		log.Printf("Error adding debug header: %v", err)
 	}
@@ -181,19 +380,147 @@ func DrawDebugLogs(frame *gocv.Mat, state *types.AppState, config types.UIConfig
 	}
 }
 
+// zoneColor returns the overlay color of a zone kind
+func zoneColor(kind zones.Kind) color.RGBA {
+	switch kind {
+	case zones.Include:
+		return Green
+	case zones.Watch:
+		return Blue
+	}
+	return Red
+}
+
+// DrawZones draws the detection zones as translucent overlays in debug mode or
+// while a zone is being drawn, along with the zone in progress
+func DrawZones(frame *gocv.Mat, state *types.AppState) {
+	if !state.DebugMode && !state.ZoneDrawing {
+		return
+	}
+
+	if len(state.Zones) > 0 {
+		overlay := frame.Clone()
+		defer func() { _ = overlay.Close() }()
+
+		for _, zone := range state.Zones {
+			if zone.Kind == zones.Watch {
+				continue
+			}
+			pts := gocv.NewPointsVectorFromPoints([][]image.Point{zone.Points})
+			_ = gocv.FillPoly(&overlay, pts, zoneColor(zone.Kind))
+			_ = gocv.Polylines(frame, pts, true, zoneColor(zone.Kind), 1)
+			pts.Close()
+		}
+		if err := gocv.AddWeighted(overlay, 0.3, *frame, 0.7, 0, frame); err != nil {
+			log.Printf("Error blending zone overlay: %v", err)
+		}
+
+		for _, zone := range state.Zones {
+			if zone.Kind == zones.Watch {
+				continue
+			}
+			if err := gocv.PutText(frame, zone.Name, zone.Points[0], gocv.FontHersheyPlain, 1.0, zoneColor(zone.Kind), 1); err != nil {
+				log.Printf("Error adding zone label: %v", err)
+			}
+		}
+	}
+
+	if state.ZoneDrawing && len(state.ZoneDraft) > 0 {
+		pts := gocv.NewPointsVectorFromPoints([][]image.Point{state.ZoneDraft})
+		defer pts.Close()
+		_ = gocv.Polylines(frame, pts, false, zoneColor(state.ZoneDraftKind), 2)
+		for _, p := range state.ZoneDraft {
+			_ = gocv.Circle(frame, p, 3, Yellow, -1)
+		}
+	}
+}
+
+// DrawWatchZones draws the outline of every watch zone with the number of
+// objects inside, in red while one of them is loitering
+func DrawWatchZones(frame *gocv.Mat, state *types.AppState) {
+	if state.Dwell == nil {
+		return
+	}
+
+	for _, zone := range state.Zones {
+		if zone.Kind != zones.Watch {
+			continue
+		}
+		stats := state.Dwell.Zone(zone.Name)
+
+		zoneOutline := zoneColor(zone.Kind)
+		label := fmt.Sprintf("%s: %d inside", zone.Name, len(stats.Present))
+		if len(stats.Loitering) > 0 {
+			zoneOutline = Red
+			label += fmt.Sprintf(", %d loitering", len(stats.Loitering))
+		}
+
+		pts := gocv.NewPointsVectorFromPoints([][]image.Point{zone.Points})
+		_ = gocv.Polylines(frame, pts, true, zoneOutline, 2)
+		pts.Close()
+		if err := gocv.PutText(frame, label, zone.Points[0].Add(image.Pt(5, 15)), gocv.FontHersheyPlain, 1.2, zoneOutline, 2); err != nil {
+			log.Printf("Error adding zone occupancy: %v", err)
+		}
+	}
+}
+
+// DrawTripwires draws the tripwire lines with their sides and running in/out
+// counts, and the line being drawn
+func DrawTripwires(frame *gocv.Mat, state *types.AppState) {
+	for _, line := range state.Tripwires {
+		_ = gocv.Line(frame, line.Start, line.End, Yellow, 2)
+
+		// Label the sides 15 px off the middle of the line, A along the normal (dy, -dx)
+		d := line.End.Sub(line.Start)
+		length := math.Hypot(float64(d.X), float64(d.Y))
+		offset := image.Pt(int(15*float64(d.Y)/length), int(-15*float64(d.X)/length))
+		mid := image.Pt((line.Start.X+line.End.X)/2, (line.Start.Y+line.End.Y)/2)
+		_ = gocv.PutText(frame, "A", mid.Add(offset), gocv.FontHersheyPlain, 1.0, Yellow, 1)
+		_ = gocv.PutText(frame, "B", mid.Sub(offset), gocv.FontHersheyPlain, 1.0, Yellow, 1)
+
+		counts := fmt.Sprintf("%s in %d out %d", line.Name, line.In, line.Out)
+		if err := gocv.PutText(frame, counts, line.Start.Add(image.Pt(5, -8)), gocv.FontHersheyPlain, 1.2, Yellow, 2); err != nil {
+			log.Printf("Error adding tripwire counts: %v", err)
+		}
+	}
+
+	if state.LineDrawing {
+		for _, p := range state.LineDraft {
+			_ = gocv.Circle(frame, p, 4, Yellow, -1)
+		}
+	}
+}
+
 // RenderFrame renders all UI elements on the frame
 func RenderFrame(frame *gocv.Mat, state *types.AppState, trackingRect image.Rectangle, trackingSuccess bool, config types.UIConfig) {
+	// Draw detection zones underneath everything else
+	DrawZones(frame, state)
+	DrawWatchZones(frame, state)
+	DrawTripwires(frame, state)
+
+	// Draw trails below the boxes they lead to
+	DrawTrajectories(frame, state)
+
 	// Draw tracking rectangle if tracking is active
-	if state.TrackingEnabled && !trackingRect.Empty() {
-		DrawTrackingRect(frame, trackingRect, trackingSuccess)
+	if state.Mode.Current().HasTarget() && !trackingRect.Empty() {
+		DrawTrackingRect(frame, trackingRect, trackingSuccess, state.TargetConfidence, state.TargetLabel, config)
+		DrawSpeed(frame, image.Pt(trackingRect.Min.X, trackingRect.Max.Y+30), state.TargetSpeed, state.MetersPerPixel, Blue)
 	}
 
+	// Draw multi-object tracks
+	DrawTracks(frame, state)
+
+	// Draw motion prediction in debug mode
+	DrawMotionEstimate(frame, state)
+
 	// Draw ROI selection if active
 	DrawROISelection(frame, state)
+	DrawMeasurement(frame, state)
 
 	// Draw status messages
 	DrawStatusMessage(frame, state, config)
 	DrawRecordingStatus(frame, state, config)
+	DrawHUD(frame, state, config)
 	DrawHelpText(frame, state, config)
 	
 	// Draw debug logs if debug mode is enabled
@@ -207,6 +534,13 @@ func PrintStartupInstructions() {
 	fmt.Println("- Press 's' to start live ROI selection")
 	fmt.Println("- In ROI mode: Arrow keys or WASD move, +/- resize, ENTER confirm, ESC cancel")
 	fmt.Println("- Press 'a' to toggle auto-tracking")
+	fmt.Println("- Press 'm' to toggle multi-object tracking")
+	fmt.Println("- Press 't' to cycle tracking algorithms")
+	fmt.Println("- Press 'p' to cycle auto-tracking target selection policies")
+	fmt.Println("- Press 'h' to toggle motion trails and '[' / ']' to shorten or lengthen them")
+	fmt.Println("- Press 'z' to draw a detection zone (click points, i/e/w include/exclude/watch, ENTER save) and 'x' to delete the last one")
+	fmt.Println("- Press 'l' to draw a tripwire line (click its start and end) and 'L' to delete the last one")
+	fmt.Println("- Press 'k' and click both ends of a reference object to set the scale for speeds in m/s")
 	fmt.Println("- Press 'r' to reset tracking")
 	fmt.Println("- Press 'v' to start/stop video recording")
 	fmt.Println("- Press 'd' to toggle debug mode (shows last N logs on screen)")
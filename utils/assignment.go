@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"image"
+	"math"
+)
+
+// IoU returns the intersection over union of two rectangles
+func IoU(a, b image.Rectangle) float64 {
+	intersection := a.Intersect(b)
+	if intersection.Empty() {
+		return 0
+	}
+
+	interArea := float64(intersection.Dx() * intersection.Dy())
+	unionArea := float64(a.Dx()*a.Dy()+b.Dx()*b.Dy()) - interArea
+	if unionArea <= 0 {
+		return 0
+	}
+	return interArea / unionArea
+}
+
+// Hungarian solves the assignment problem for a rows×cols cost matrix and
+// returns, for each row, the assigned column or -1. The matrix doesn't have to be square.
+func Hungarian(cost [][]float64) []int {
+	rows := len(cost)
+	if rows == 0 {
+		return nil
+	}
+	cols := len(cost[0])
+
+	// Pad to a square matrix; padded cells cost nothing and are dropped afterwards
+	n := rows
+	if cols > n {
+		n = cols
+	}
+	a := make([][]float64, n+1)
+	for i := range a {
+		a[i] = make([]float64, n+1)
+	}
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			a[i+1][j+1] = cost[i][j]
+		}
+	}
+
+	// Shortest augmenting path formulation with row/column potentials (1-indexed)
+	u := make([]float64, n+1)
+	v := make([]float64, n+1)
+	p := make([]int, n+1)
+	way := make([]int, n+1)
+
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, n+1)
+		used := make([]bool, n+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+
+		for {
+			used[j0] = true
+			i0 := p[j0]
+			delta := math.Inf(1)
+			j1 := 0
+			for j := 1; j <= n; j++ {
+				if used[j] {
+					continue
+				}
+				cur := a[i0][j] - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	assignment := make([]int, rows)
+	for i := range assignment {
+		assignment[i] = -1
+	}
+	for j := 1; j <= n; j++ {
+		if p[j] > 0 && p[j] <= rows && j <= cols {
+			assignment[p[j]-1] = j - 1
+		}
+	}
+	return assignment
+}
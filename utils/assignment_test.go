@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"image"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestIoU(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b image.Rectangle
+		want float64
+	}{
+		{"identical", image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 10), 1},
+		{"disjoint", image.Rect(0, 0, 10, 10), image.Rect(20, 20, 30, 30), 0},
+		{"touching", image.Rect(0, 0, 10, 10), image.Rect(10, 0, 20, 10), 0},
+		{"nested", image.Rect(0, 0, 10, 10), image.Rect(0, 0, 5, 10), 0.5},
+		{"half overlap", image.Rect(0, 0, 10, 10), image.Rect(5, 0, 15, 10), 50.0 / 150},
+		{"empty", image.Rect(0, 0, 10, 10), image.Rectangle{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IoU(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("IoU(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := IoU(tt.b, tt.a); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("IoU(%v, %v) = %v, want %v", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHungarian(t *testing.T) {
+	tests := []struct {
+		name string
+		cost [][]float64
+		want []int
+	}{
+		{"empty", nil, nil},
+		{"single", [][]float64{{3}}, []int{0}},
+		{
+			"greedy would be wrong",
+			[][]float64{
+				{1, 2},
+				{2, 10},
+			},
+			[]int{1, 0},
+		},
+		{
+			"square",
+			[][]float64{
+				{4, 1, 3},
+				{2, 0, 5},
+				{3, 2, 2},
+			},
+			[]int{1, 0, 2},
+		},
+		{
+			"more rows than columns",
+			[][]float64{
+				{1, 9},
+				{9, 1},
+				{5, 5},
+			},
+			[]int{0, 1, -1},
+		},
+		{
+			"more columns than rows",
+			[][]float64{
+				{7, 1, 9},
+			},
+			[]int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hungarian(tt.cost); !slices.Equal(got, tt.want) {
+				t.Errorf("Hungarian(%v) = %v, want %v", tt.cost, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,50 +2,94 @@ package utils
 
 import (
 	"image"
+	"math"
 )
 
-// ConstrainBoundingBox prevents the bounding box from growing too large
-func ConstrainBoundingBox(rect image.Rectangle, initialSize int, maxGrowth float64, minSize, imgWidth, imgHeight int) image.Rectangle {
-	// Calculate current size
-	currentWidth := rect.Dx()
-	currentHeight := rect.Dy()
+// RectCenter returns the center point of a rectangle
+func RectCenter(rect image.Rectangle) image.Point {
+	return image.Pt(rect.Min.X+rect.Dx()/2, rect.Min.Y+rect.Dy()/2)
+}
 
-	// Calculate maximum allowed size based on initial size
-	maxAllowedSize := int(float64(initialSize) * maxGrowth)
+// SizeReference is the expected size of a tracked box. It starts at the size
+// of the initial ROI and slowly follows the tracked size, so that a target
+// approaching or leaving the camera can change size while jumps are damped.
+type SizeReference struct {
+	Width  float64
+	Height float64
+}
 
-	// Constrain width and height
-	newWidth := currentWidth
-	newHeight := currentHeight
+// NewSizeReference returns a reference with the size of rect
+func NewSizeReference(rect image.Rectangle) SizeReference {
+	return SizeReference{Width: float64(rect.Dx()), Height: float64(rect.Dy())}
+}
+
+// Empty reports whether the reference has no area
+func (r SizeReference) Empty() bool {
+	return r.Width <= 0 || r.Height <= 0
+}
 
-	if currentWidth > maxAllowedSize {
-		newWidth = maxAllowedSize
+// Adapt moves the reference towards the size of rect by a fraction rate (0-1)
+func (r SizeReference) Adapt(rect image.Rectangle, rate float64) SizeReference {
+	if r.Empty() {
+		return NewSizeReference(rect)
 	}
-	if currentHeight > maxAllowedSize {
-		newHeight = maxAllowedSize
+	return SizeReference{
+		Width:  r.Width + rate*(float64(rect.Dx())-r.Width),
+		Height: r.Height + rate*(float64(rect.Dy())-r.Height),
+	}
+}
+
+// Scale returns the reference scaled by factor, e.g. when the camera zooms
+func (r SizeReference) Scale(factor float64) SizeReference {
+	return SizeReference{Width: r.Width * factor, Height: r.Height * factor}
+}
+
+// SizeLimits bounds how the size of a tracked box may change
+type SizeLimits struct {
+	// MaxGrowth is the largest factor the box may grow or shrink by relative to the reference
+	MaxGrowth float64
+	// MaxStep is the largest relative size change from the previous box, e.g. 0.1 for 10% per frame
+	MaxStep float64
+	// MinSize is the smallest side length in px
+	MinSize int
+}
+
+// ConstrainBoundingBox resizes the box to the aspect ratio of the reference,
+// with its size bounded relative to the previous box and to the reference.
+// The box keeps its center but is moved to stay within the image.
+func ConstrainBoundingBox(rect, previous image.Rectangle, reference SizeReference, limits SizeLimits, imgWidth, imgHeight int) image.Rectangle {
+	if rect.Empty() || reference.Empty() {
+		return rect
 	}
 
-	// Ensure minimum size
-	if newWidth < minSize {
-		newWidth = minSize
+	// Scale relative to the reference, by area so that a box that only gets
+	// wider or taller still counts as growing
+	refArea := reference.Width * reference.Height
+	scale := math.Sqrt(float64(rect.Dx()*rect.Dy()) / refArea)
+
+	// Bound the change per frame
+	if !previous.Empty() && limits.MaxStep > 0 {
+		prevScale := math.Sqrt(float64(previous.Dx()*previous.Dy()) / refArea)
+		scale = clamp(scale, prevScale/(1+limits.MaxStep), prevScale*(1+limits.MaxStep))
 	}
-	if newHeight < minSize {
-		newHeight = minSize
+
+	// Bound the change relative to the reference
+	if limits.MaxGrowth > 0 {
+		scale = clamp(scale, 1/limits.MaxGrowth, limits.MaxGrowth)
 	}
 
-	// Calculate center point of original rectangle
-	centerX := rect.Min.X + rect.Dx()/2
-	centerY := rect.Min.Y + rect.Dy()/2
+	// Keep the aspect ratio, growing both sides if the shorter one is too small
+	width, height := reference.Width*scale, reference.Height*scale
+	if short := math.Min(width, height); short < float64(limits.MinSize) {
+		width *= float64(limits.MinSize) / short
+		height *= float64(limits.MinSize) / short
+	}
 
 	// Create new rectangle centered on the same point
-	halfWidth := newWidth / 2
-	halfHeight := newHeight / 2
-
-	newRect := image.Rect(
-		centerX-halfWidth,
-		centerY-halfHeight,
-		centerX+halfWidth,
-		centerY+halfHeight,
-	)
+	center := RectCenter(rect)
+	newWidth := int(math.Round(width))
+	newHeight := int(math.Round(height))
+	newRect := image.Rect(0, 0, newWidth, newHeight).Add(image.Pt(center.X-newWidth/2, center.Y-newHeight/2))
 
 	// Ensure the rectangle stays within image bounds
 	if newRect.Min.X < 0 {
@@ -63,3 +107,8 @@ func ConstrainBoundingBox(rect image.Rectangle, initialSize int, maxGrowth float
 
 	return newRect
 }
+
+// clamp limits v to the range [lo, hi]
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
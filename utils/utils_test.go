@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestConstrainBoundingBox(t *testing.T) {
+	person := SizeReference{Width: 40, Height: 100}
+	limits := SizeLimits{MaxGrowth: 2, MaxStep: 0.1, MinSize: 20}
+	still := image.Rect(100, 100, 140, 200)
+
+	tests := []struct {
+		name      string
+		rect      image.Rectangle
+		previous  image.Rectangle
+		reference SizeReference
+		limits    SizeLimits
+		want      image.Rectangle
+	}{
+		{"unchanged", still, still, person, limits, still},
+		{"square keeps aspect ratio", image.Rect(85, 115, 155, 185), still, person, limits, image.Rect(98, 95, 142, 205)},
+		{"growth bounded per frame", image.Rect(80, 50, 160, 250), still, person, limits, image.Rect(98, 95, 142, 205)},
+		{"shrink bounded per frame", image.Rect(110, 125, 130, 175), still, person, limits, image.Rect(102, 105, 138, 196)},
+		{"growth bounded by reference", image.Rect(220, 50, 420, 550), image.Rectangle{}, person, limits, image.Rect(280, 200, 360, 400)},
+		{"shrink bounded by reference", image.Rect(116, 140, 124, 160), image.Rectangle{}, person, limits, image.Rect(110, 125, 130, 175)},
+		{"minimum size keeps aspect ratio", image.Rect(115, 138, 125, 163), image.Rectangle{}, person, SizeLimits{MaxGrowth: 2, MinSize: 30}, image.Rect(105, 113, 135, 188)},
+		{"moved inside image", image.Rect(610, 420, 650, 520), image.Rect(610, 420, 650, 520), person, limits, image.Rect(600, 380, 640, 480)},
+		{"no reference", image.Rect(85, 115, 155, 185), still, SizeReference{}, limits, image.Rect(85, 115, 155, 185)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ConstrainBoundingBox(tt.rect, tt.previous, tt.reference, tt.limits, 640, 480)
+			if got != tt.want {
+				t.Errorf("ConstrainBoundingBox(%v, %v) = %v, want %v", tt.rect, tt.previous, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSizeReferenceAdapt(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference SizeReference
+		rect      image.Rectangle
+		rate      float64
+		want      SizeReference
+	}{
+		{"moves by rate", SizeReference{Width: 40, Height: 100}, image.Rect(0, 0, 60, 80), 0.1, SizeReference{Width: 42, Height: 98}},
+		{"rate 0 keeps reference", SizeReference{Width: 40, Height: 100}, image.Rect(0, 0, 60, 80), 0, SizeReference{Width: 40, Height: 100}},
+		{"rate 1 takes size", SizeReference{Width: 40, Height: 100}, image.Rect(0, 0, 60, 80), 1, SizeReference{Width: 60, Height: 80}},
+		{"empty takes size", SizeReference{}, image.Rect(10, 10, 50, 110), 0.1, SizeReference{Width: 40, Height: 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.reference.Adapt(tt.rect, tt.rate)
+			if math.Abs(got.Width-tt.want.Width) > 1e-9 || math.Abs(got.Height-tt.want.Height) > 1e-9 {
+				t.Errorf("Adapt(%v, %v) = %+v, want %+v", tt.rect, tt.rate, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package zones
+
+import (
+	"image"
+	"sort"
+	"time"
+)
+
+// EventKind is what happened between an object and a watch zone
+type EventKind string
+
+const (
+	// Enter is an object entering a zone
+	Enter EventKind = "enter"
+	// Leave is an object leaving a zone
+	Leave EventKind = "leave"
+	// Loiter is an object staying in a zone longer than the loitering time
+	Loiter EventKind = "loiter"
+)
+
+// Event is an object entering, leaving or loitering in a watch zone. Dwell
+// is how long the object has been inside, for leave and loiter events.
+type Event struct {
+	Zone   string
+	Object string
+	Kind   EventKind
+	Time   time.Duration
+	Dwell  time.Duration
+}
+
+// DwellStats summarizes the stays of objects in a watch zone
+type DwellStats struct {
+	// Visits counts completed stays, Total and Longest are their durations
+	Visits  int
+	Total   time.Duration
+	Longest time.Duration
+
+	// Present maps the objects inside the zone to when they entered
+	Present map[string]time.Duration
+	// Loitering holds the objects inside for longer than the loitering time
+	Loitering map[string]bool
+}
+
+// Average returns the mean duration of the completed stays
+func (s *DwellStats) Average() time.Duration {
+	if s.Visits == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Visits)
+}
+
+// Dwell follows which objects are inside the watch zones and for how long
+type Dwell struct {
+	// LoiterTime is how long an object may stay in a zone before it is loitering, 0 disables alerts
+	LoiterTime time.Duration
+	// Stats holds the statistics of every watch zone by name
+	Stats map[string]*DwellStats
+}
+
+// NewDwell creates an empty dwell tracker with the given loitering time
+func NewDwell(loiterTime time.Duration) *Dwell {
+	return &Dwell{LoiterTime: loiterTime, Stats: make(map[string]*DwellStats)}
+}
+
+// Zone returns the statistics of a zone, creating them on first use
+func (d *Dwell) Zone(name string) *DwellStats {
+	stats, ok := d.Stats[name]
+	if !ok {
+		stats = &DwellStats{Present: make(map[string]time.Duration), Loitering: make(map[string]bool)}
+		d.Stats[name] = stats
+	}
+	return stats
+}
+
+// Update takes the positions of all objects at time now and returns what
+// happened in the watch zones since the previous update. Objects missing from
+// positions have left every zone.
+func (d *Dwell) Update(zones []Zone, positions map[string]image.Point, now time.Duration) []Event {
+	objects := make([]string, 0, len(positions))
+	for object := range positions {
+		objects = append(objects, object)
+	}
+	sort.Strings(objects)
+
+	var events []Event
+	for _, zone := range zones {
+		if zone.Kind != Watch {
+			continue
+		}
+		stats := d.Zone(zone.Name)
+
+		inside := make(map[string]bool)
+		for _, object := range objects {
+			if !zone.Contains(positions[object]) {
+				continue
+			}
+			inside[object] = true
+
+			entered, present := stats.Present[object]
+			switch {
+			case !present:
+				stats.Present[object] = now
+				events = append(events, Event{Zone: zone.Name, Object: object, Kind: Enter, Time: now})
+			case d.LoiterTime > 0 && !stats.Loitering[object] && now-entered >= d.LoiterTime:
+				stats.Loitering[object] = true
+				events = append(events, Event{Zone: zone.Name, Object: object, Kind: Loiter, Time: now, Dwell: now - entered})
+			}
+		}
+
+		var left []string
+		for object := range stats.Present {
+			if !inside[object] {
+				left = append(left, object)
+			}
+		}
+		sort.Strings(left)
+		for _, object := range left {
+			events = append(events, stats.leave(zone.Name, object, now))
+		}
+	}
+	return events
+}
+
+// Close ends the stays of all objects still inside a zone at time now, e.g.
+// at the end of a session, so that they count towards the statistics
+func (d *Dwell) Close(now time.Duration) []Event {
+	names := make([]string, 0, len(d.Stats))
+	for name := range d.Stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var events []Event
+	for _, name := range names {
+		stats := d.Stats[name]
+		objects := make([]string, 0, len(stats.Present))
+		for object := range stats.Present {
+			objects = append(objects, object)
+		}
+		sort.Strings(objects)
+		for _, object := range objects {
+			events = append(events, stats.leave(name, object, now))
+		}
+	}
+	return events
+}
+
+// Remove drops the statistics of a deleted zone
+func (d *Dwell) Remove(name string) {
+	delete(d.Stats, name)
+}
+
+// leave ends the stay of an object in the zone and adds it to the statistics
+func (s *DwellStats) leave(zone, object string, now time.Duration) Event {
+	dwell := now - s.Present[object]
+	s.Visits++
+	s.Total += dwell
+	if dwell > s.Longest {
+		s.Longest = dwell
+	}
+	delete(s.Present, object)
+	delete(s.Loitering, object)
+	return Event{Zone: zone, Object: object, Kind: Leave, Time: now, Dwell: dwell}
+}
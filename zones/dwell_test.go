@@ -0,0 +1,83 @@
+package zones
+
+import (
+	"image"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDwellUpdate(t *testing.T) {
+	lobby := Zone{Name: "lobby", Kind: Watch, Points: []image.Point{{0, 0}, {100, 0}, {100, 100}, {0, 100}}}
+	door := Zone{Name: "door", Kind: Exclude, Points: lobby.Points}
+	inside, outside := image.Pt(50, 50), image.Pt(150, 50)
+
+	steps := []struct {
+		at        time.Duration
+		positions map[string]image.Point
+		want      []Event
+	}{
+		{0, map[string]image.Point{"target": outside}, nil},
+		{1 * time.Second, map[string]image.Point{"target": inside, "track 1": inside}, []Event{
+			{Zone: "lobby", Object: "target", Kind: Enter, Time: 1 * time.Second},
+			{Zone: "lobby", Object: "track 1", Kind: Enter, Time: 1 * time.Second},
+		}},
+		{3 * time.Second, map[string]image.Point{"target": outside, "track 1": inside}, []Event{
+			{Zone: "lobby", Object: "target", Kind: Leave, Time: 3 * time.Second, Dwell: 2 * time.Second},
+		}},
+		{6 * time.Second, map[string]image.Point{"track 1": inside}, []Event{
+			{Zone: "lobby", Object: "track 1", Kind: Loiter, Time: 6 * time.Second, Dwell: 5 * time.Second},
+		}},
+		{7 * time.Second, map[string]image.Point{"track 1": inside}, nil},
+		{8 * time.Second, map[string]image.Point{}, []Event{
+			{Zone: "lobby", Object: "track 1", Kind: Leave, Time: 8 * time.Second, Dwell: 7 * time.Second},
+		}},
+	}
+
+	dwell := NewDwell(5 * time.Second)
+	for _, step := range steps {
+		got := dwell.Update([]Zone{lobby, door}, step.positions, step.at)
+		if !reflect.DeepEqual(got, step.want) {
+			t.Fatalf("Update at %v = %+v, want %+v", step.at, got, step.want)
+		}
+	}
+
+	stats := dwell.Stats["lobby"]
+	if stats.Visits != 2 || stats.Total != 9*time.Second || stats.Longest != 7*time.Second || stats.Average() != 4500*time.Millisecond {
+		t.Errorf("stats = %d visits, total %v, longest %v, average %v, want 2, 9s, 7s, 4.5s",
+			stats.Visits, stats.Total, stats.Longest, stats.Average())
+	}
+	if len(stats.Present) != 0 || len(stats.Loitering) != 0 {
+		t.Errorf("objects still present: %v, loitering: %v", stats.Present, stats.Loitering)
+	}
+	if _, ok := dwell.Stats["door"]; ok {
+		t.Error("exclude zone has dwell statistics")
+	}
+}
+
+func TestDwellClose(t *testing.T) {
+	lobby := Zone{Name: "lobby", Kind: Watch, Points: []image.Point{{0, 0}, {100, 0}, {100, 100}, {0, 100}}}
+
+	dwell := NewDwell(0)
+	dwell.Update([]Zone{lobby}, map[string]image.Point{"track 1": {50, 50}, "track 2": {60, 60}}, 1*time.Second)
+
+	got := dwell.Close(5 * time.Second)
+	want := []Event{
+		{Zone: "lobby", Object: "track 1", Kind: Leave, Time: 5 * time.Second, Dwell: 4 * time.Second},
+		{Zone: "lobby", Object: "track 2", Kind: Leave, Time: 5 * time.Second, Dwell: 4 * time.Second},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Close = %+v, want %+v", got, want)
+	}
+
+	stats := dwell.Stats["lobby"]
+	if stats.Visits != 2 || stats.Total != 8*time.Second || stats.Longest != 4*time.Second || len(stats.Present) != 0 {
+		t.Errorf("stats = %d visits, total %v, longest %v, %d present, want 2, 8s, 4s, 0",
+			stats.Visits, stats.Total, stats.Longest, len(stats.Present))
+	}
+
+	dwell.Remove("lobby")
+	if _, ok := dwell.Stats["lobby"]; ok {
+		t.Error("removed zone still has statistics")
+	}
+}
@@ -0,0 +1,145 @@
+package zones
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+
+	"gocv.io/x/gocv"
+)
+
+// Kind is what a zone does to detection
+type Kind string
+
+const (
+	// Include restricts auto-tracking to objects inside the zone
+	Include Kind = "include"
+	// Exclude ignores all motion inside the zone
+	Exclude Kind = "exclude"
+	// Watch doesn't affect detection but records how long objects stay inside
+	Watch Kind = "watch"
+)
+
+// Zone is a named polygon in working frame coordinates
+type Zone struct {
+	Name   string        `json:"name"`
+	Kind   Kind          `json:"kind"`
+	Points []image.Point `json:"points"`
+}
+
+// Contains reports whether p lies inside the zone polygon
+func (z Zone) Contains(p image.Point) bool {
+	inside := false
+	for i, j := 0, len(z.Points)-1; i < len(z.Points); j, i = i, i+1 {
+		a, b := z.Points[i], z.Points[j]
+		// Count polygon edges crossed by a ray from p to the right
+		if (a.Y > p.Y) != (b.Y > p.Y) {
+			crossX := a.X + (p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y)
+			if p.X < crossX {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// UniqueName returns a name for a new zone of the given kind, such as
+// watch-3, that no existing zone has
+func UniqueName(zones []Zone, kind Kind) string {
+	taken := make(map[string]bool, len(zones))
+	for _, zone := range zones {
+		taken[zone.Name] = true
+	}
+	for n := len(zones) + 1; ; n++ {
+		if name := fmt.Sprintf("%s-%d", kind, n); !taken[name] {
+			return name
+		}
+	}
+}
+
+// Load reads zones from a JSON file. A missing file means no zones.
+func Load(path string) ([]Zone, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read zones file: %v", err)
+	}
+
+	var zones []Zone
+	if err := json.Unmarshal(data, &zones); err != nil {
+		return nil, fmt.Errorf("could not parse zones file %s: %v", path, err)
+	}
+	for _, zone := range zones {
+		if zone.Kind != Include && zone.Kind != Exclude && zone.Kind != Watch {
+			return nil, fmt.Errorf("zone %q has unknown kind %q", zone.Name, zone.Kind)
+		}
+		if len(zone.Points) < 3 {
+			return nil, fmt.Errorf("zone %q needs at least 3 points", zone.Name)
+		}
+	}
+	return zones, nil
+}
+
+// Save writes zones to a JSON file
+func Save(path string, zones []Zone) error {
+	data, err := json.MarshalIndent(zones, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode zones: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("could not write zones file: %v", err)
+	}
+	return nil
+}
+
+// MaskExcluded clears the exclude zones in a foreground mask so that motion
+// inside them is never detected. The mask is scale times the size of the
+// frame the zones were drawn on.
+func MaskExcluded(mask *gocv.Mat, zones []Zone, scale float64) error {
+	var polygons [][]image.Point
+	for _, zone := range zones {
+		if zone.Kind != Exclude {
+			continue
+		}
+		points := make([]image.Point, len(zone.Points))
+		for i, p := range zone.Points {
+			points[i] = image.Pt(int(math.Round(float64(p.X)*scale)), int(math.Round(float64(p.Y)*scale)))
+		}
+		polygons = append(polygons, points)
+	}
+	if len(polygons) == 0 {
+		return nil
+	}
+
+	pts := gocv.NewPointsVectorFromPoints(polygons)
+	defer pts.Close()
+
+	return gocv.FillPoly(mask, pts, color.RGBA{})
+}
+
+// Allows reports whether auto-tracking may pick an object with the given
+// bounding box: its center must be outside every exclude zone and inside an
+// include zone, if there are any
+func Allows(zones []Zone, rect image.Rectangle) bool {
+	center := image.Pt(rect.Min.X+rect.Dx()/2, rect.Min.Y+rect.Dy()/2)
+
+	hasInclude, included := false, false
+	for _, zone := range zones {
+		switch zone.Kind {
+		case Exclude:
+			if zone.Contains(center) {
+				return false
+			}
+		case Include:
+			hasInclude = true
+			included = included || zone.Contains(center)
+		}
+	}
+	return included || !hasInclude
+}
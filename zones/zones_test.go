@@ -0,0 +1,25 @@
+package zones
+
+import "testing"
+
+func TestUniqueName(t *testing.T) {
+	tests := []struct {
+		name  string
+		zones []Zone
+		kind  Kind
+		want  string
+	}{
+		{"first", nil, Watch, "watch-1"},
+		{"next", []Zone{{Name: "exclude-1"}}, Watch, "watch-2"},
+		{"after a deleted zone", []Zone{{Name: "watch-2"}}, Watch, "watch-3"},
+		{"skips taken names", []Zone{{Name: "watch-2"}, {Name: "watch-3"}}, Watch, "watch-4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UniqueName(tt.zones, tt.kind); got != tt.want {
+				t.Errorf("UniqueName() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}